@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Shopify/themekit/src/check"
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+// checkTotalBudget will check the size of the whole theme in the project directory
+// against the total size budget, so that nothing is deployed when it is over a
+// strict budget
+func checkTotalBudget(ctx *cmdutil.Ctx) error {
+	budgets, err := check.NewBudgets(ctx.Env.SizeBudgets)
+	if err != nil {
+		return fmt.Errorf("[%s] %s", colors.Green(ctx.Env.Name), err)
+	} else if !budgets.HasTotal() {
+		return nil
+	}
+	size, err := check.Size(ctx.Env)
+	if err != nil {
+		return err
+	}
+	if err := overBudget(ctx, budgets.Total(size)); err != nil {
+		return fmt.Errorf("[%s] %s, nothing was deployed", colors.Green(ctx.Env.Name), err)
+	}
+	return nil
+}
+
+// checkFileBudget will check the size of an asset that was read to be uploaded
+// against the size budgets that it matches
+func checkFileBudget(ctx *cmdutil.Ctx, asset shopify.Asset) error {
+	if len(ctx.Env.SizeBudgets) == 0 {
+		return nil
+	}
+	budgets, err := check.NewBudgets(ctx.Env.SizeBudgets)
+	if err != nil {
+		return err
+	}
+	contents, err := asset.Contents()
+	if err != nil {
+		return err
+	}
+	return overBudget(ctx, budgets.File(asset.Key, int64(len(contents))))
+}
+
+// overBudget will warn about the budgets that were exceeded, or return them as an
+// error if the budgets are strict
+func overBudget(ctx *cmdutil.Ctx, problems []check.Problem) error {
+	if len(problems) == 0 {
+		return nil
+	} else if ctx.Env.StrictSizeBudgets {
+		messages := []string{}
+		for _, problem := range problems {
+			messages = append(messages, problem.Message)
+		}
+		return errors.New(strings.Join(messages, ", "))
+	}
+	for _, problem := range problems {
+		ctx.Log.Printf("[%s] %s %s", colors.Green(ctx.Env.Name), colors.Yellow("Warning:"), problem)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+func TestDeploySizeBudgets(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"assets/big.js":   `var big = 1;`,
+		"assets/small.js": `var a;`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Env.SizeBudgets = map[string]string{"assets/*.js": "8B"}
+	client.On("GetAllAssets").Return([]shopify.Asset{}, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return !isManifest(a) }), "").Return(nil)
+	expectManifest(client)
+	assert.Nil(t, deploy(ctx))
+	assert.Contains(t, stdOut.String(), "Warning: assets/big.js: is 12B, over the 8B budget for assets/*.js")
+	assert.NotContains(t, stdOut.String(), "assets/small.js: is")
+	client.AssertNumberOfCalls(t, "UpdateAsset", 3)
+
+	ctx, client, _, _, stdErr := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Env.SizeBudgets = map[string]string{"assets/*.js": "8B"}
+	ctx.Env.StrictSizeBudgets = true
+	client.On("GetAllAssets").Return([]shopify.Asset{}, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "assets/small.js" }), "").Return(nil)
+	assert.Nil(t, deploy(ctx))
+	assert.Contains(t, stdErr.String(), "assets/big.js) not uploaded because it is 12B, over the 8B budget for assets/*.js")
+	client.AssertNumberOfCalls(t, "UpdateAsset", 1)
+	client.AssertNotCalled(t, "UpdateAsset", mock.MatchedBy(isManifest), "")
+}
+
+func TestDeployTotalSizeBudget(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{"assets/app.js": `var a = 1;`})
+	defer os.RemoveAll(dir)
+
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Env.SizeBudgets = map[string]string{"total": "4B"}
+	ctx.Env.StrictSizeBudgets = true
+	err := deploy(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "the theme is 10B, over the 4B total budget, nothing was deployed")
+	}
+	client.AssertNotCalled(t, "GetAllAssets")
+
+	ctx.Env.StrictSizeBudgets = false
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js"}}, nil)
+	client.On("UpdateAsset", mock.Anything, "").Return(nil)
+	assert.Nil(t, deploy(ctx))
+	assert.Contains(t, stdOut.String(), "Warning: the theme is 10B, over the 4B total budget")
+
+	ctx.Env.SizeBudgets = map[string]string{"total": "big"}
+	err = deploy(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid size budget for total")
+	}
+}
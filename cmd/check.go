@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Shopify/themekit/src/check"
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the theme for problems that shopify would reject it for",
+	Long: `Check will look through all of the theme files in your project directory
+ that are not ignored for problems that shopify would reject during a deploy, so
+ that they can be fixed beforehand. It checks that:
+
+ - the theme has layout/theme.liquid, its config files and an index template
+ - no two files compile to the same name, like [filename].js and [filename].js.liquid
+ - files are directly inside of a theme directory
+ - files are not larger than shopify allows, or than the size_budgets in your
+   config
+ - json files, and the schema of sections, are valid json
+ - liquid tags like if, for and schema are closed
+ - config/settings_data.json, json templates and section groups only have the
+   settings, blocks and sections that their schemas define, with values of the
+   right type and in range
+
+ Pass --settings to only check the settings. Pass --validate-settings to deploy to
+ check them before deploying.
+
+ Problems are printed as file:line: message, and check exits with an error if
+ any are found.
+
+ Check does not connect to shopify so your config does not need a password,
+ theme id or store.
+ `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmdutil.ForLocalEnv(flags, args, checkTheme)
+	},
+}
+
+func checkTheme(ctx *cmdutil.Ctx) error {
+	checkFiles := check.Theme
+	if ctx.Flags.SettingsOnly {
+		checkFiles = checkSettings
+	}
+	problems, err := checkFiles(ctx.Env)
+	if err != nil {
+		return fmt.Errorf("[%s] could not check the theme: %s", colors.Green(ctx.Env.Name), err)
+	}
+	for _, problem := range problems {
+		printProblem(ctx, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("[%s] found %d problems in the theme", colors.Green(ctx.Env.Name), len(problems))
+	}
+	ctx.Log.Printf("[%s] No problems found", colors.Green(ctx.Env.Name))
+	return nil
+}
+
+func displayPath(directory, key string) string {
+	path := filepath.Join(directory, filepath.FromSlash(key))
+	if wd, err := os.Getwd(); err == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil {
+				return rel
+			}
+		}
+	}
+	return path
+}
+
+// validateSettings will check the settings of the theme in the project directory
+// and return an error if they are not valid
+func validateSettings(ctx *cmdutil.Ctx) error {
+	problems, err := checkSettings(ctx.Env)
+	if err != nil {
+		return fmt.Errorf("[%s] could not check the settings: %s", colors.Green(ctx.Env.Name), err)
+	}
+	for _, problem := range problems {
+		printProblem(ctx, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("[%s] found %d problems in the settings, nothing was deployed", colors.Green(ctx.Env.Name), len(problems))
+	}
+	return nil
+}
+
+func checkSettings(e *env.Env) ([]check.Problem, error) {
+	keys, err := shopify.AssetKeys(e)
+	if err != nil {
+		return nil, err
+	}
+	return check.Settings(keys, func(key string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(e.Directory, filepath.FromSlash(key)))
+	})
+}
+
+// printProblem will print the problem with the path to its file relative to the
+// working directory when it can, so that editors can open it from the output
+func printProblem(ctx *cmdutil.Ctx, problem check.Problem) {
+	if problem.Key != "" {
+		problem.Key = displayPath(ctx.Env.Directory, problem.Key)
+	}
+	ctx.Log.Print(problem.String())
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTheme(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"layout/theme.liquid":         "{{ content_for_layout }}\n{% if a %}",
+		"config/settings_schema.json": `[]`,
+		"config/settings_data.json":   `{"current": {}}`,
+		"templates/index.json":        `{}`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, _, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	err := checkTheme(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "found 1 problems in the theme")
+	}
+	assert.Contains(t, stdOut.String(), filepath.Join("layout", "theme.liquid")+":2: if is not closed")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "layout", "theme.liquid"), []byte(`{{ content_for_layout }}`), 0644))
+	stdOut.Reset()
+	assert.Nil(t, checkTheme(ctx))
+	assert.Contains(t, stdOut.String(), "No problems found")
+
+	ctx.Env.Directory = filepath.Join(dir, "nope")
+	assert.NotNil(t, checkTheme(ctx))
+}
+
+func TestCheckThemeSettings(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"config/settings_schema.json": `[{"name": "Colors", "settings": [{"type": "color", "id": "color_text"}]}]`,
+		"config/settings_data.json":   `{"current": {"color_text": "#000", "old": 1}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, _, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Flags.SettingsOnly = true
+	err := checkTheme(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "found 1 problems in the theme")
+	}
+	assert.Contains(t, stdOut.String(), filepath.Join("config", "settings_data.json")+": current.old is not a setting of config/settings_schema.json")
+	assert.NotContains(t, stdOut.String(), "missing layout/theme.liquid")
+}
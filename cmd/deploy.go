@@ -4,20 +4,46 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
-	"sync"
 	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/Shopify/themekit/src/cmdutil"
 	"github.com/Shopify/themekit/src/colors"
 	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/git"
+	"github.com/Shopify/themekit/src/jsonmerge"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
 )
 
 const settingsDataKey = "config/settings_data.json"
 
+// stateMaxAge is how long after the files on shopify were listed that the sync
+// state is trusted instead of listing them again
+const stateMaxAge = 15 * time.Minute
+
+const (
+	// defaultMaxDeletePercent is the percentage of the files on shopify that deploy
+	// will remove without confirmation if no max_delete_percent is configured
+	defaultMaxDeletePercent = 50
+	// minCheckedDeletes is how many files have to be removed before the percentage
+	// is checked, so that small themes can still be cleaned up
+	minCheckedDeletes = 10
+)
+
+// These are the ways deploy can resolve a file that was changed on shopify since
+// it was last synced.
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictDownload  = "download"
+)
+
 var compiledFilenameWarning = template.Must(template.New("compiledFilenamesWarning").Parse(
 	`[{{.EnvName}}] You have file names that will conflict with each other.
 If you have files named [filename].js.liquid or [filename].scss.liquid,
@@ -43,10 +69,72 @@ var deployCmd = &cobra.Command{
  exist on your local machine will be removed from shopify unless the --nodelete
  flag is passed
 
+ Files that were changed on shopify, for instance in the online editor, since they
+ were last synced will not be overwritten and are reported as conflicts. Pass
+ --on-conflict=overwrite to replace them anyway or --on-conflict=download to
+ replace your local copy with the version on shopify. JSON templates and locales
+ are merged with the changes on shopify instead when the changes do not conflict.
+ Deploy exits with an error when conflicts were left unresolved, after uploading
+ the rest of the files.
+
+ Files are uploaded in phases, assets and locales first, then snippets, sections,
+ templates, layouts and finally config/settings_data.json, so that a file is on
+ shopify before any file that renders it. A file is not uploaded if a file it
+ renders failed to upload, and no files are removed if any upload failed.
+
+ Pass --since with a git ref, like a commit or a branch, to only deploy the files
+ that were added, changed, renamed or deleted since that ref, including files that
+ are not committed yet. Deleted files are removed from shopify unless the --nodelete
+ flag is passed.
+
+ Deploy lists the files on shopify to find what has changed. Pass --changed to
+ compare against the checksums from the last sync instead when the files were
+ listed within the last 15 minutes. Only files that themekit has synced are then
+ removed from shopify.
+
+ To protect against a misconfigured directory removing a whole theme, deploy will
+ not remove more than half of the files on shopify, or more than max_deletes or
+ max_delete_percent from your config, without confirmation. Pass --force-delete,
+ or --yes, to remove them anyway.
+
+ Deploy locks the theme while it runs so that another deploy, or watch, cannot
+ change the theme at the same time. Pass --steal-lock to take a lock that was left
+ behind by a process that did not finish.
+ The lock is public, so it names its holder with the deployer from your config
+ or the account that started a CI build, and never your user or host.
+
+ After a successful deploy that changed files, the themekit version, git commit,
+ deployer, time and changed files are recorded on the theme in
+ assets/themekit_manifest.json, or the manifest_key from your config. Run
+ 'theme info' to read it back. The manifest is public so the deployer is the
+ deployer from your config or the account that started a CI build, never your
+ user or host.
+
+ The planned and completed file operations are recorded in the .themekit
+ directory while deploying. If a deploy does not finish, pass --resume to only
+ carry out the operations that did not complete, in the order they were planned.
+
+ The files that failed to deploy, and why, are recorded in the .themekit
+ directory. Pass --retry-failed to only deploy those files again.
+
+ Theme settings changed in the customizer are kept if the --merge-settings flag
+ is passed. config/settings_data.json is then merged with the version on shopify,
+ where the settings on shopify win unless they are listed in the managed_settings
+ of your config.
+
+ Pass --validate-settings to check config/settings_data.json and the json
+ templates against the schemas of the theme settings and sections before
+ deploying. Nothing is deployed if they are not valid, see 'theme check'.
+
+ Files larger than the size_budgets in your config, like "assets/*.jpg": 500KB,
+ are warned about, and so is the whole theme if it is larger than the total
+ budget. If strict_size_budgets is set those files are not uploaded instead, and
+ nothing is deployed if the theme is over its total budget.
+
  For more information, refer to https://shopify.dev/tools/theme-kit/command-reference#deploy.
  `,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return cmdutil.ForEachClient(flags, args, deploy)
+		return cmdutil.ForEachClient(flags, args, withLock("deploy", deploy))
 	},
 	PostRun: func(cmd *cobra.Command, args []string) {
 	},
@@ -57,71 +145,372 @@ func deploy(ctx *cmdutil.Ctx) error {
 		return fmt.Errorf("[%s] environment is readonly", colors.Green(ctx.Env.Name))
 	}
 
-	assetsActions, err := generateActions(ctx)
+	switch ctx.Flags.OnConflict {
+	case "", conflictSkip, conflictOverwrite, conflictDownload:
+	default:
+		return fmt.Errorf("[%s] invalid --on-conflict value %q, expected one of %s, %s or %s", colors.Green(ctx.Env.Name), ctx.Flags.OnConflict, conflictSkip, conflictOverwrite, conflictDownload)
+	}
+
+	if ctx.Flags.Since != "" && len(ctx.Args) > 0 {
+		return fmt.Errorf("[%s] file names cannot be passed with --since", colors.Green(ctx.Env.Name))
+	} else if ctx.Flags.Resume && (ctx.Flags.Since != "" || len(ctx.Args) > 0) {
+		return fmt.Errorf("[%s] file names and --since cannot be passed with --resume", colors.Green(ctx.Env.Name))
+	} else if ctx.Flags.RetryFailed && (ctx.Flags.Since != "" || ctx.Flags.Resume || len(ctx.Args) > 0) {
+		return fmt.Errorf("[%s] file names, --since and --resume cannot be passed with --retry-failed", colors.Green(ctx.Env.Name))
+	}
+
+	if ctx.Flags.ValidateSettings {
+		if err := validateSettings(ctx); err != nil {
+			return err
+		}
+	}
+	if err := checkTotalBudget(ctx); err != nil {
+		return err
+	}
+
+	plan, journal, err := startDeploy(ctx)
 	if err != nil {
 		return err
 	}
+	defer journal.Close()
+	defer saveFailures(ctx, "deploy")
+
+	ctx.StartProgress(len(plan.ops))
+	for _, step := range plan.steps {
+		ctx.Run(plan.tasks(step), func(task cmdutil.Task) file.Op {
+			if failedDep := plan.failedDependency(task.Path); failedDep != "" {
+				plan.fail(task.Path)
+				ctx.Failed(task.Path, fmt.Errorf("%s, which it renders, failed to upload", failedDep))
+				ctx.Err("[%s] (%s) not uploaded because %s, which it renders, failed to upload", colors.Green(ctx.Env.Name), colors.Blue(task.Path), colors.Blue(failedDep))
+				return file.Skip
+			}
+			op, err := deployAsset(ctx, task.Path, task.Op)
+			if err != nil {
+				plan.fail(task.Path)
+				ctx.Failed(task.Path, err)
+			} else {
+				plan.done(task.Path, op)
+				journal.Done(task.Path)
+			}
+			return op
+		})
+	}
+
+	if plan.failed() && len(plan.removals) > 0 {
+		ctx.Err("[%s] %d files were not removed from shopify because some files failed to upload", colors.Green(ctx.Env.Name), len(plan.removals))
+		for _, path := range plan.removals {
+			ctx.Failed(path, errors.New("not removed because some files failed to upload"))
+			ctx.DoneTask(file.Skip)
+		}
+		return nil
+	}
+
+	ctx.Run(plan.tasks(plan.removals), func(task cmdutil.Task) file.Op {
+		if err := perform(ctx, task.Path, task.Op, ""); err != nil {
+			plan.fail(task.Path)
+			ctx.Failed(task.Path, err)
+		} else {
+			plan.done(task.Path, task.Op)
+			journal.Done(task.Path)
+		}
+		return task.Op
+	})
 
-	var deployGroup sync.WaitGroup
-	ctx.StartProgress(len(assetsActions))
-	for path, op := range assetsActions {
-		if path == settingsDataKey {
-			defer perform(ctx, path, op, "")
-			continue
+	if plan.failed() {
+		return nil
+	}
+	journal.Finish()
+	if len(plan.updated)+len(plan.removed) > 0 {
+		if err := writeManifest(ctx, plan.updated, plan.removed); err != nil {
+			ctx.Err("[%s] could not record the deploy in %s: %s", colors.Green(ctx.Env.Name), colors.Blue(file.ManifestKey(ctx.Env)), err)
 		}
-		deployGroup.Add(1)
-		go func(path string, op file.Op) {
-			defer deployGroup.Done()
-			perform(ctx, path, op, "")
-		}(path, op)
 	}
+	return nil
+}
+
+// startDeploy will plan a new deploy and start its journal or, with the resume
+// flag, plan the operations that an unfinished deploy did not complete
+func startDeploy(ctx *cmdutil.Ctx) (*deployPlan, *state.Journal, error) {
+	if ctx.Flags.Resume {
+		journal, err := ctx.State.ResumeJournal("deploy")
+		if err == state.ErrNoJournal {
+			return nil, nil, fmt.Errorf("[%s] there is no unfinished deploy to resume", colors.Green(ctx.Env.Name))
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("[%s] could not read the unfinished deploy: %s", colors.Green(ctx.Env.Name), err)
+		}
+		return resumePlan(ctx, journal.Remaining()), journal, nil
+	}
+
+	assetsActions, err := generateActions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan := planDeploy(ctx, assetsActions)
+	journal, err := ctx.State.StartJournal("deploy", plan.journalSteps())
+	if err != nil {
+		ctx.ErrLog.Printf("[%s] could not record the deploy plan, it will not be possible to resume this deploy: %s", colors.Green(ctx.Env.Name), err)
+	}
+	return plan, journal, nil
+}
 
-	deployGroup.Wait()
+// deployAsset will carry out a single planned file operation and return the file
+// operation that was actually carried out.
+func deployAsset(ctx *cmdutil.Ctx, path string, op file.Op) (file.Op, error) {
+	if op == file.Merge {
+		return mergeAsset(ctx, path, true)
+	} else if path == settingsDataKey && ctx.Flags.MergeSettings && (op == file.Update || op == file.Conflict) {
+		return file.Update, mergeSettingsData(ctx)
+	}
+	return op, perform(ctx, path, op, lastSyncedChecksum(ctx, path, op))
+}
 
+// mergeSettingsData will merge the local settings data with the settings data on
+// shopify and upload the result, so that changes made in the customizer are kept.
+func mergeSettingsData(ctx *cmdutil.Ctx) error {
+	local, err := shopify.ReadAsset(ctx.Env, settingsDataKey)
+	if err != nil {
+		ctx.Err("[%s] error loading %s: %s", colors.Green(ctx.Env.Name), colors.Green(settingsDataKey), colors.Red(err))
+		return err
+	}
+
+	merged := local
+	remote, err := ctx.Client.GetAsset(settingsDataKey)
+	if err != nil && err != shopify.ErrNotPartOfTheme {
+		ctx.Err("[%s] error downloading %s: %s", colors.Green(ctx.Env.Name), colors.Blue(settingsDataKey), err)
+		return err
+	} else if err == nil {
+		data, err := jsonmerge.Settings([]byte(local.Value), []byte(remote.Value), ctx.Env.ManagedSettings)
+		if err != nil {
+			ctx.Err("[%s] error merging %s: %s", colors.Green(ctx.Env.Name), colors.Blue(settingsDataKey), err)
+			return err
+		}
+		merged = shopify.NewAsset(settingsDataKey, data)
+	}
+
+	if err = ctx.Client.UpdateAsset(merged, remote.Checksum); err != nil {
+		ctx.Err("[%s] (%s) %s", colors.Green(ctx.Env.Name), colors.Blue(settingsDataKey), err)
+		return err
+	}
+	recordSync(ctx, merged)
+	if ctx.Flags.Verbose {
+		ctx.Log.Printf("[%s] Merged %s with the settings on shopify", colors.Green(ctx.Env.Name), colors.Blue(settingsDataKey))
+	}
 	return nil
 }
 
 func generateActions(ctx *cmdutil.Ctx) (map[string]file.Op, error) {
 	assetsActions := map[string]file.Op{}
-	pathsToChecksums := map[string]string{}
 
-	remoteFiles, err := ctx.Client.GetAllAssets()
+	pathsToChecksums, err := remoteChecksums(ctx)
 	if err != nil {
 		return assetsActions, err
 	}
-	for _, remoteAsset := range remoteFiles {
-		if len(ctx.Args) == 0 && !ctx.Flags.NoDelete {
-			assetsActions[remoteAsset.Key] = file.Remove
+	paths := ctx.Args
+	if ctx.Flags.Since != "" || ctx.Flags.RetryFailed {
+		changes, err := changedFiles(ctx)
+		if err != nil {
+			return assetsActions, err
+		}
+		for _, path := range changes.Deleted {
+			if _, onRemote := pathsToChecksums[path]; onRemote && !ctx.Flags.NoDelete {
+				assetsActions[path] = file.Remove
+			}
+		}
+		if len(changes.Changed) == 0 {
+			return assetsActions, checkDeletes(ctx, assetsActions, len(pathsToChecksums))
+		}
+		paths = changes.Changed
+	} else {
+		for path := range pathsToChecksums {
+			if len(ctx.Args) == 0 && !ctx.Flags.NoDelete {
+				assetsActions[path] = file.Remove
+			}
 		}
-		pathsToChecksums[remoteAsset.Key] = remoteAsset.Checksum
 	}
 
-	localAssets, err := shopify.FindAssets(ctx.Env, ctx.Args...)
+	localAssets, err := shopify.ScanAssets(ctx.Env, ctx.State, paths...)
 	if err != nil {
 		return assetsActions, err
 	}
 
-	problemAssets := compileAssetFilenames(localAssets)
+	filenames := []string{}
+	for _, asset := range localAssets {
+		filenames = append(filenames, asset.Key)
+	}
+	problemAssets := compileAssetFilenames(filenames)
 	if len(problemAssets) > 0 {
 		return assetsActions, compiledAssetWarning(ctx.Env.Name, problemAssets)
 	}
 
 	for _, asset := range localAssets {
 		var path = asset.Key
-		if asset.Checksum != "" && (asset.Checksum == pathsToChecksums[asset.Key]) {
+		remoteChecksum, onRemote := pathsToChecksums[path]
+		switch {
+		case asset.Checksum != "" && asset.Checksum == remoteChecksum:
 			assetsActions[path] = file.Skip
-		} else {
+			recordLocalSync(ctx, path, asset.Checksum)
+		case onRemote && changedSinceSync(ctx, path, remoteChecksum):
+			assetsActions[path] = deployConflictAction(ctx, path)
+		default:
+			if !onRemote {
+				ctx.State.Forget(path)
+			}
 			assetsActions[path] = file.Update
 		}
 	}
-	return assetsActions, nil
+	return assetsActions, checkDeletes(ctx, assetsActions, len(pathsToChecksums))
 }
 
-func compileAssetFilenames(assets []shopify.Asset) (problemAssets []string) {
-	var filenames []string
-	for _, asset := range assets {
-		filenames = append(filenames, asset.Key)
+// checkDeletes will return an error if more files would be removed from shopify
+// than the environment allows, unless forced or confirmed.
+func checkDeletes(ctx *cmdutil.Ctx, actions map[string]file.Op, remoteCount int) error {
+	removals := []string{}
+	for path, op := range actions {
+		if op == file.Remove {
+			removals = append(removals, path)
+		}
+	}
+	if ctx.Flags.ForceDelete || !tooManyDeletes(ctx, len(removals), remoteCount) {
+		return nil
+	}
+
+	sort.Strings(removals)
+	for _, path := range removals {
+		ctx.Log.Printf("[%s] %s %s", colors.Green(ctx.Env.Name), colors.Red("Remove"), colors.Blue(path))
+	}
+	question := fmt.Sprintf("[%s] Remove %d of the %d files on shopify?", colors.Green(ctx.Env.Name), len(removals), remoteCount)
+	if ctx.Confirm(question) {
+		return nil
+	}
+	return fmt.Errorf(
+		"[%s] deploy would remove %d of the %d files on shopify, check the directory in your config or pass --force-delete to remove them anyway",
+		colors.Green(ctx.Env.Name), len(removals), remoteCount,
+	)
+}
+
+func tooManyDeletes(ctx *cmdutil.Ctx, removals, remoteCount int) bool {
+	if ctx.Env.MaxDeletes > 0 && removals > ctx.Env.MaxDeletes {
+		return true
+	}
+	percent := ctx.Env.MaxDeletePercent
+	if percent <= 0 {
+		percent = defaultMaxDeletePercent
+	}
+	return removals > minCheckedDeletes && removals*100 > remoteCount*percent
+}
+
+// changedFiles will return the files to deploy when not deploying the whole theme,
+// either the files changed since a git ref or the files that failed to deploy
+func changedFiles(ctx *cmdutil.Ctx) (git.Changes, error) {
+	if ctx.Flags.RetryFailed {
+		return retryChanges(ctx)
+	}
+	return changedSince(ctx)
+}
+
+// retryChanges will return the files that failed the last deploy, split into the
+// files that still exist locally and the files that have been deleted.
+func retryChanges(ctx *cmdutil.Ctx) (git.Changes, error) {
+	changes := git.Changes{Changed: []string{}, Deleted: []string{}}
+	paths, err := failedPaths(ctx, "deploy")
+	if err != nil {
+		return changes, err
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(ctx.Env.Directory, filepath.FromSlash(path))); os.IsNotExist(err) {
+			changes.Deleted = append(changes.Deleted, path)
+		} else {
+			changes.Changed = append(changes.Changed, path)
+		}
 	}
+	return changes, nil
+}
+
+// changedSince will return the theme files that have changed since the git ref
+// passed with the since flag, leaving out any files that are ignored.
+func changedSince(ctx *cmdutil.Ctx) (git.Changes, error) {
+	changes, err := git.Diff(ctx.Env.Directory, ctx.Flags.Since)
+	if err != nil {
+		return changes, fmt.Errorf("[%s] could not find the files changed since %s: %s", colors.Green(ctx.Env.Name), ctx.Flags.Since, err)
+	}
+
+	filter, err := file.NewEnvFilter(ctx.Env)
+	if err != nil {
+		return changes, err
+	}
+	themeFiles := func(paths []string) []string {
+		kept := []string{}
+		for _, path := range paths {
+			if !filter.Match(path) {
+				kept = append(kept, path)
+			}
+		}
+		return kept
+	}
+	return git.Changes{Changed: themeFiles(changes.Changed), Deleted: themeFiles(changes.Deleted)}, nil
+}
+
+// remoteChecksums will return the checksum of every file on shopify. With the
+// changed flag, and a sync state that is fresh, the checksums from the last sync
+// are used instead of listing the files.
+func remoteChecksums(ctx *cmdutil.Ctx) (map[string]string, error) {
+	if ctx.Flags.Changed {
+		if ctx.State.Fresh(stateMaxAge) {
+			return ctx.State.Checksums(), nil
+		} else if ctx.Flags.Verbose {
+			ctx.Log.Printf("[%s] files on shopify were not listed recently, listing them now", colors.Green(ctx.Env.Name))
+		}
+	}
+
+	checksums := map[string]string{}
+	remoteFiles, err := ctx.Client.GetAllAssets()
+	if err != nil {
+		return checksums, err
+	}
+	ctx.State.Listed()
+	for _, remoteAsset := range remoteFiles {
+		checksums[remoteAsset.Key] = remoteAsset.Checksum
+	}
+	return checksums, nil
+}
+
+// changedSinceSync will return true if the remote checksum is different from the
+// checksum recorded at the last sync, meaning the file was edited on shopify.
+func changedSinceSync(ctx *cmdutil.Ctx, path, remoteChecksum string) bool {
+	lastChecksum, synced := ctx.State.Checksum(path)
+	return synced && remoteChecksum != "" && remoteChecksum != lastChecksum
+}
+
+func deployConflictAction(ctx *cmdutil.Ctx, path string) file.Op {
+	switch ctx.Flags.OnConflict {
+	case conflictOverwrite:
+		// forgetting the last sync means the upload will not be sent with a checksum
+		// precondition and so will overwrite the remote changes
+		ctx.State.Forget(path)
+		return file.Update
+	case conflictDownload:
+		return file.Get
+	}
+	if canMerge(ctx, path) {
+		return file.Merge
+	}
+	return file.Conflict
+}
+
+// lastSyncedChecksum returns the checksum that an upload should expect the remote
+// file to still have, so that changes made since planning are not overwritten.
+func lastSyncedChecksum(ctx *cmdutil.Ctx, path string, op file.Op) string {
+	if op != file.Update {
+		return ""
+	}
+	checksum, _ := ctx.State.Checksum(path)
+	return checksum
+}
+
+// compileAssetFilenames will return the files that would overwrite each other
+// because shopify compiles [filename].liquid to [filename]
+func compileAssetFilenames(filenames []string) (problemAssets []string) {
+	filenames = append([]string{}, filenames...)
 	sort.Strings(filenames)
 	for i, filename := range filenames {
 		if i < len(filenames)-1 && filename+".liquid" == filenames[i+1] {
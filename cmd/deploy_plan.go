@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/liquid"
+	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
+)
+
+// deployPhases are the directories that are uploaded one after the other, so that
+// files are on shopify before the files that render them. Files in directories
+// that are not listed are uploaded in the first phase, and settings data is
+// always uploaded last.
+var deployPhases = [][]string{
+	{"assets", "config", "locales"},
+	{"snippets"},
+	{"sections"},
+	{"templates", "templates/customers"},
+	{"layout"},
+}
+
+// deployPlan is the order that a deploy will carry out its file operations
+type deployPlan struct {
+	// steps are run one after the other, the files within a step concurrently
+	steps [][]string
+	// removals are only run once all of the steps have succeeded
+	removals []string
+	// deps are the files, uploaded by this deploy, that each file renders
+	deps map[string][]string
+	// ops are the file operations planned for every file
+	ops map[string]file.Op
+
+	mu       sync.Mutex
+	failures map[string]bool
+	// updated and removed are the files that were changed on shopify
+	updated []string
+	removed []string
+}
+
+// planDeploy will order the file operations into steps. Files are first grouped
+// by their deploy phase and then, within a phase, files that render other files
+// in the same phase are uploaded after them.
+func planDeploy(ctx *cmdutil.Ctx, actions map[string]file.Op) *deployPlan {
+	plan := newDeployPlan(actions)
+
+	phases := make([][]string, len(deployPhases)+1)
+	for key, op := range actions {
+		if op == file.Remove {
+			plan.removals = append(plan.removals, key)
+			continue
+		}
+		phase := deployPhase(key)
+		phases[phase] = append(phases[phase], key)
+		if op == file.Update || op == file.Merge {
+			plan.deps[key] = uploadedReferences(ctx, key, actions)
+		}
+	}
+	sort.Strings(plan.removals)
+
+	for _, keys := range phases {
+		plan.steps = append(plan.steps, levelSteps(keys, plan.deps)...)
+	}
+	return plan
+}
+
+// resumePlan will plan the operations that an unfinished deploy did not complete,
+// in the steps that they were planned in
+func resumePlan(ctx *cmdutil.Ctx, steps [][]state.JournalEntry) *deployPlan {
+	plan := newDeployPlan(map[string]file.Op{})
+	for _, step := range steps {
+		keys := []string{}
+		for _, entry := range step {
+			plan.ops[entry.Path] = entry.Op
+			if entry.Op == file.Remove {
+				plan.removals = append(plan.removals, entry.Path)
+			} else {
+				keys = append(keys, entry.Path)
+			}
+		}
+		if len(keys) > 0 {
+			plan.steps = append(plan.steps, keys)
+		}
+	}
+	for key, op := range plan.ops {
+		if op == file.Update || op == file.Merge {
+			plan.deps[key] = uploadedReferences(ctx, key, plan.ops)
+		}
+	}
+	return plan
+}
+
+func newDeployPlan(actions map[string]file.Op) *deployPlan {
+	return &deployPlan{deps: map[string][]string{}, ops: actions, failures: map[string]bool{}}
+}
+
+// journalSteps will return the steps of the plan, with the removals as the last
+// step, to be recorded in a journal
+func (plan *deployPlan) journalSteps() [][]state.JournalEntry {
+	steps := [][]state.JournalEntry{}
+	planned := append([][]string{}, plan.steps...)
+	for _, step := range append(planned, plan.removals) {
+		if len(step) == 0 {
+			continue
+		}
+		entries := []state.JournalEntry{}
+		for _, key := range step {
+			entries = append(entries, state.JournalEntry{Path: key, Op: plan.ops[key]})
+		}
+		steps = append(steps, entries)
+	}
+	return steps
+}
+
+// tasks will return the file operations for the keys of a step
+func (plan *deployPlan) tasks(keys []string) []cmdutil.Task {
+	tasks := []cmdutil.Task{}
+	for _, key := range keys {
+		tasks = append(tasks, cmdutil.Task{Path: key, Op: plan.ops[key]})
+	}
+	return tasks
+}
+
+func deployPhase(key string) int {
+	if key == settingsDataKey {
+		return len(deployPhases)
+	}
+	dir := path.Dir(key)
+	for i, dirs := range deployPhases {
+		for _, phaseDir := range dirs {
+			if dir == phaseDir {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// uploadedReferences will return the files that the file renders which are also
+// being uploaded by this deploy. Only liquid and json files are read.
+func uploadedReferences(ctx *cmdutil.Ctx, key string, actions map[string]file.Op) []string {
+	if ext := path.Ext(key); ext != ".liquid" && ext != ".json" {
+		return nil
+	}
+	asset, err := shopify.ReadAsset(ctx.Env, key)
+	if err != nil || asset.Value == "" {
+		return nil
+	}
+	refs := []string{}
+	for _, ref := range liquid.References(key, []byte(asset.Value)) {
+		if op, found := actions[ref]; found && ref != key && (op == file.Update || op == file.Merge) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// levelSteps will split the keys of a phase into steps so that every file comes
+// after the files it renders. Files that render each other in a cycle are put in
+// the last step.
+func levelSteps(keys []string, deps map[string][]string) [][]string {
+	if len(keys) == 0 {
+		return nil
+	}
+	inPhase := map[string]bool{}
+	for _, key := range keys {
+		inPhase[key] = true
+	}
+
+	levels := map[string]int{}
+	var level func(key string, visiting map[string]bool) int
+	level = func(key string, visiting map[string]bool) int {
+		if l, ok := levels[key]; ok {
+			return l
+		} else if visiting[key] {
+			return len(keys)
+		}
+		visiting[key] = true
+		l := 0
+		for _, dep := range deps[key] {
+			if inPhase[dep] {
+				if depLevel := level(dep, visiting) + 1; depLevel > l {
+					l = depLevel
+				}
+			}
+		}
+		delete(visiting, key)
+		if l > len(keys) {
+			l = len(keys)
+		}
+		levels[key] = l
+		return l
+	}
+
+	byLevel := map[int][]string{}
+	for _, key := range keys {
+		l := level(key, map[string]bool{})
+		byLevel[l] = append(byLevel[l], key)
+	}
+
+	steps := [][]string{}
+	for l := 0; l <= len(keys); l++ {
+		if step, ok := byLevel[l]; ok {
+			sort.Strings(step)
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func (plan *deployPlan) fail(key string) {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	plan.failures[key] = true
+}
+
+// done will record a file operation that was carried out
+func (plan *deployPlan) done(key string, op file.Op) {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	switch op {
+	case file.Update, file.Merge:
+		plan.updated = append(plan.updated, key)
+	case file.Remove:
+		plan.removed = append(plan.removed, key)
+	}
+}
+
+func (plan *deployPlan) failed() bool {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	return len(plan.failures) > 0
+}
+
+// failedDependency will return a file that the key renders that failed to upload
+func (plan *deployPlan) failedDependency(key string) string {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	for _, dep := range plan.deps[key] {
+		if plan.failures[dep] {
+			return dep
+		}
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
+)
+
+func createThemeDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "themekit-theme")
+	assert.Nil(t, err)
+	for key, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	return dir
+}
+
+func TestPlanDeploy(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"layout/theme.liquid":       `{% section 'header' %}{% render 'card' %}`,
+		"templates/index.json":      `{"sections": {"main": {"type": "header"}}}`,
+		"sections/header.liquid":    `{% render 'icon' %}`,
+		"snippets/card.liquid":      `{% render 'price' %}{% render 'icon' %}`,
+		"snippets/price.liquid":     `{% render 'money' %}`,
+		"snippets/money.liquid":     `$`,
+		"snippets/icon.liquid":      `<svg/>`,
+		"snippets/loop-a.liquid":    `{% render 'loop-b' %}`,
+		"snippets/loop-b.liquid":    `{% render 'loop-a' %}`,
+		"assets/app.js":             `var a;`,
+		"config/settings_data.json": `{}`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, _, _, _, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	actions := map[string]file.Op{
+		"layout/theme.liquid":       file.Update,
+		"templates/index.json":      file.Update,
+		"sections/header.liquid":    file.Update,
+		"snippets/card.liquid":      file.Update,
+		"snippets/price.liquid":     file.Update,
+		"snippets/money.liquid":     file.Update,
+		"snippets/icon.liquid":      file.Skip,
+		"snippets/loop-a.liquid":    file.Update,
+		"snippets/loop-b.liquid":    file.Update,
+		"assets/app.js":             file.Update,
+		"config/settings_data.json": file.Update,
+		"snippets/old.liquid":       file.Remove,
+		"assets/old.js":             file.Remove,
+	}
+
+	plan := planDeploy(ctx, actions)
+	assert.Equal(t, [][]string{
+		{"assets/app.js"},
+		{"snippets/icon.liquid", "snippets/money.liquid"},
+		{"snippets/price.liquid"},
+		{"snippets/card.liquid"},
+		{"snippets/loop-a.liquid", "snippets/loop-b.liquid"},
+		{"sections/header.liquid"},
+		{"templates/index.json"},
+		{"layout/theme.liquid"},
+		{"config/settings_data.json"},
+	}, plan.steps)
+	assert.Equal(t, []string{"assets/old.js", "snippets/old.liquid"}, plan.removals)
+	assert.Equal(t, []string{"sections/header.liquid", "snippets/card.liquid"}, plan.deps["layout/theme.liquid"])
+
+	assert.False(t, plan.failed())
+	assert.Equal(t, "", plan.failedDependency("snippets/card.liquid"))
+	plan.fail("snippets/price.liquid")
+	assert.True(t, plan.failed())
+	assert.Equal(t, "snippets/price.liquid", plan.failedDependency("snippets/card.liquid"))
+}
+
+func TestDeploySkipsDependentsAndRemovalsOnFailure(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"snippets/card.liquid":  `{% render 'price' %}`,
+		"snippets/price.liquid": `$`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, client, _, _, stdErr := createTestCtx()
+	ctx.Env.Directory = dir
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "snippets/old.liquid"}}, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "snippets/price.liquid" }), "").Return(fmt.Errorf("server error"))
+	assert.Nil(t, deploy(ctx))
+	assert.Contains(t, stdErr.String(), "snippets/card.liquid) not uploaded because snippets/price.liquid, which it renders, failed to upload")
+	assert.Contains(t, stdErr.String(), "1 files were not removed from shopify")
+	client.AssertNotCalled(t, "DeleteAsset", mock.Anything)
+	client.AssertNumberOfCalls(t, "UpdateAsset", 1)
+}
+
+func TestDeployResume(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"assets/app.js":         `app`,
+		"snippets/card.liquid":  `{% render 'price' %}`,
+		"snippets/price.liquid": `$`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.State, _ = state.Load(ctx.Env)
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "snippets/old.liquid"}}, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "assets/app.js" }), "").Return(nil).Once()
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "snippets/price.liquid" }), "").Return(fmt.Errorf("server error")).Once()
+	assert.Nil(t, deploy(ctx))
+	client.AssertExpectations(t)
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.State, _ = state.Load(ctx.Env)
+	ctx.Flags.Resume = true
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "snippets/price.liquid" }), "").Return(nil).Once()
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "snippets/card.liquid" }), "").Return(nil).Once()
+	client.On("DeleteAsset", shopify.Asset{Key: "snippets/old.liquid"}).Return(nil).Once()
+	expectManifest(client)
+	assert.Nil(t, deploy(ctx))
+	client.AssertExpectations(t)
+	client.AssertNotCalled(t, "GetAllAssets")
+
+	_, err := ctx.State.ResumeJournal("deploy")
+	assert.Equal(t, state.ErrNoJournal, err)
+	err = deploy(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "no unfinished deploy to resume")
+	}
+
+	ctx.Args = []string{"assets/app.js"}
+	assert.NotNil(t, deploy(ctx))
+}
+
+func TestDeployRetryFailed(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"assets/app.js":         `app`,
+		"snippets/card.liquid":  `{% render 'price' %}`,
+		"snippets/price.liquid": `$`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.State, _ = state.Load(ctx.Env)
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "snippets/old.liquid"}}, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "assets/app.js" }), "").Return(nil).Once()
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "snippets/price.liquid" }), "").Return(fmt.Errorf("server error")).Once()
+	assert.Nil(t, deploy(ctx))
+	assert.Contains(t, stdOut.String(), "Pass --retry-failed to deploy only the 3 files that failed")
+	failures, err := ctx.State.Failures("deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, []state.Failure{
+		{Path: "snippets/card.liquid", Cause: "snippets/price.liquid, which it renders, failed to upload"},
+		{Path: "snippets/old.liquid", Cause: "not removed because some files failed to upload"},
+		{Path: "snippets/price.liquid", Cause: "server error"},
+	}, failures)
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.State, _ = state.Load(ctx.Env)
+	ctx.Flags.RetryFailed = true
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js"}, {Key: "snippets/old.liquid"}}, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "snippets/price.liquid" }), "").Return(nil).Once()
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == "snippets/card.liquid" }), "").Return(nil).Once()
+	client.On("DeleteAsset", shopify.Asset{Key: "snippets/old.liquid"}).Return(nil).Once()
+	expectManifest(client)
+	assert.Nil(t, deploy(ctx))
+	client.AssertExpectations(t)
+
+	err = deploy(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "there are no failed files to retry")
+	}
+
+	ctx.Args = []string{"assets/app.js"}
+	assert.NotNil(t, deploy(ctx))
+}
@@ -3,15 +3,22 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
 	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/env"
 	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
 )
 
 func TestUploadSingleFile(t *testing.T) {
@@ -45,6 +52,7 @@ func TestUploadWithVerboseOptions(t *testing.T) {
 	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js"}}, nil)
 	// This checksum corresponds to a zero-byte file
 	client.On("UpdateAsset", shopify.Asset{Key: "assets/app.js", Checksum: "d41d8cd98f00b204e9800998ecf8427e"}, "").Return(nil)
+	expectManifest(client)
 	err := deploy(ctx)
 	assert.Nil(t, err)
 	assert.Contains(t, stdOut.String(), "Updated assets/app.js")
@@ -70,6 +78,7 @@ func TestUploadForSkipFileWhenChecksumsMatch(t *testing.T) {
 	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "config/settings_data.json", Checksum: "d41d8cd98f00b204e9800998ecf8427e"}}, nil)
 	// the _testdirectory contains two assets. We expect one to be uploaded, one to be skipped.
 	client.On("UpdateAsset", shopify.Asset{Key: "assets/app.js", Checksum: "d41d8cd98f00b204e9800998ecf8427e"}, "").Return(nil)
+	expectManifest(client)
 	err := deploy(ctx)
 	assert.Nil(t, err)
 	assert.Contains(t, stdOut.String(), "Skipped config/settings_data.json")
@@ -93,7 +102,7 @@ func TestReplace(t *testing.T) {
 	ctx.Flags.Verbose = true
 	ctx.Env.Directory = filepath.Join("_testdata", "projectdir")
 	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/logo.png"}}, nil)
-	client.On("UpdateAsset", mock.MatchedBy(func(shopify.Asset) bool { return true }), "").Return(nil).Times(2)
+	client.On("UpdateAsset", mock.MatchedBy(func(shopify.Asset) bool { return true }), "").Return(nil).Times(3)
 	client.On("DeleteAsset", mock.MatchedBy(func(shopify.Asset) bool { return true })).Return(nil).Once()
 	err := deploy(ctx)
 	assert.Nil(t, err)
@@ -108,6 +117,31 @@ func TestReplace(t *testing.T) {
 	}
 }
 
+func TestDeployValidateSettings(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"config/settings_schema.json": `[{"name": "Colors", "settings": [{"type": "checkbox", "id": "show_cart"}]}]`,
+		"config/settings_data.json":   `{"current": {"show_cart": "yes"}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Flags.ValidateSettings = true
+	err := deploy(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "found 1 problems in the settings, nothing was deployed")
+	}
+	assert.Contains(t, stdOut.String(), "current.show_cart is a string, but checkbox settings have to be a boolean")
+	client.AssertNotCalled(t, "GetAllAssets")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "config", "settings_data.json"), []byte(`{"current": {"show_cart": true}}`), 0644))
+	client.On("GetAllAssets").Return([]shopify.Asset{}, fmt.Errorf("server error"))
+	err = deploy(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "server error")
+	}
+}
+
 func TestGenerateActions(t *testing.T) {
 	ctx, client, _, _, _ := createTestCtx()
 	ctx.Env.Directory = filepath.Join("_testdata", "projectdir")
@@ -149,13 +183,221 @@ func TestGenerateActions(t *testing.T) {
 	assert.Equal(t, tpl.String(), err.Error())
 }
 
+func TestGenerateActionsWithConflicts(t *testing.T) {
+	remote := []shopify.Asset{
+		{Key: "assets/app.js", Checksum: "edited-online"},
+		{Key: "config/settings_data.json", Checksum: "last-sync"},
+	}
+	testcases := []struct {
+		onConflict string
+		appOp      file.Op
+	}{
+		{onConflict: "", appOp: file.Conflict},
+		{onConflict: conflictSkip, appOp: file.Conflict},
+		{onConflict: conflictOverwrite, appOp: file.Update},
+		{onConflict: conflictDownload, appOp: file.Get},
+	}
+
+	for _, testcase := range testcases {
+		ctx, client, _, _, _ := createTestCtx()
+		ctx.Env.Directory = filepath.Join("_testdata", "projectdir")
+		ctx.Flags.OnConflict = testcase.onConflict
+		ctx.State = &state.Sync{Assets: map[string]state.Entry{
+			"assets/app.js":             {Checksum: "last-sync"},
+			"config/settings_data.json": {Checksum: "last-sync"},
+		}}
+		client.On("GetAllAssets").Return(remote, nil)
+		actions, err := generateActions(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, testcase.appOp, actions["assets/app.js"])
+		assert.Equal(t, file.Update, actions["config/settings_data.json"])
+		assert.Equal(t, "last-sync", lastSyncedChecksum(ctx, "config/settings_data.json", file.Update))
+	}
+}
+
+func TestGenerateActionsChanged(t *testing.T) {
+	appChecksum, err := shopify.ReadChecksum(&env.Env{Directory: filepath.Join("_testdata", "projectdir")}, nil, "assets/app.js")
+	assert.Nil(t, err)
+
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.Directory = filepath.Join("_testdata", "projectdir")
+	ctx.Flags.Changed = true
+	ctx.State = &state.Sync{ListedAt: time.Now(), Assets: map[string]state.Entry{
+		"assets/app.js":             {Checksum: appChecksum},
+		"config/settings_data.json": {Checksum: "last-sync"},
+		"assets/deleted.js":         {Checksum: "last-sync"},
+	}}
+	actions, err := generateActions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, file.Skip, actions["assets/app.js"])
+	assert.Equal(t, file.Update, actions["config/settings_data.json"])
+	assert.Equal(t, file.Remove, actions["assets/deleted.js"])
+	client.AssertNotCalled(t, "GetAllAssets")
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Directory = filepath.Join("_testdata", "projectdir")
+	ctx.Flags.Changed = true
+	ctx.State = &state.Sync{ListedAt: time.Now().Add(-time.Hour), Assets: map[string]state.Entry{}}
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/logo.png"}}, nil)
+	actions, err = generateActions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, file.Remove, actions["assets/logo.png"])
+	assert.True(t, ctx.State.Fresh(stateMaxAge))
+	client.AssertExpectations(t)
+}
+
+func TestGenerateActionsSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := createThemeDir(t, map[string]string{
+		"assets/app.js":        "app",
+		"assets/same.js":       "same",
+		"layout/theme.liquid":  "layout",
+		"snippets/old.liquid":  "old",
+		"package.json":         "{}",
+		"templates/index.json": "{}",
+	})
+	defer os.RemoveAll(dir)
+	git := func(args ...string) {
+		out, err := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...).CombinedOutput()
+		assert.Nil(t, err, string(out))
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("changed"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "package.json"), []byte("{\"changed\": true}"), 0644))
+	assert.Nil(t, os.Remove(filepath.Join(dir, "snippets", "old.liquid")))
+	assert.Nil(t, os.Remove(filepath.Join(dir, "layout", "theme.liquid")))
+
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Flags.Since = "HEAD"
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js"}, {Key: "assets/same.js"}, {Key: "snippets/old.liquid"}}, nil)
+	actions, err := generateActions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]file.Op{"assets/app.js": file.Update, "snippets/old.liquid": file.Remove}, actions)
+
+	ctx.Flags.NoDelete = true
+	actions, err = generateActions(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]file.Op{"assets/app.js": file.Update}, actions)
+
+	ctx.Flags.Since = "nope"
+	_, err = generateActions(ctx)
+	assert.NotNil(t, err)
+
+	ctx.Args = []string{"assets/app.js"}
+	assert.NotNil(t, deploy(ctx))
+}
+
+func TestCheckDeletes(t *testing.T) {
+	removeAll := func(count int) map[string]file.Op {
+		actions := map[string]file.Op{"assets/app.js": file.Update}
+		for i := 0; i < count; i++ {
+			actions[fmt.Sprintf("assets/%d.js", i)] = file.Remove
+		}
+		return actions
+	}
+
+	testcases := []struct {
+		removals, remote, maxDeletes, maxPercent int
+		force                                    bool
+		answer                                   string
+		err                                      bool
+	}{
+		{removals: 5, remote: 5},
+		{removals: 20, remote: 100},
+		{removals: 60, remote: 100, err: true},
+		{removals: 60, remote: 100, force: true},
+		{removals: 60, remote: 100, maxPercent: 80},
+		{removals: 20, remote: 100, maxPercent: 10, err: true},
+		{removals: 5, remote: 100, maxDeletes: 3, err: true},
+		{removals: 60, remote: 100, answer: "y\n"},
+		{removals: 60, remote: 100, answer: "n\n", err: true},
+	}
+
+	for i, testcase := range testcases {
+		ctx, _, _, stdOut, _ := createTestCtx()
+		ctx.Env.MaxDeletes = testcase.maxDeletes
+		ctx.Env.MaxDeletePercent = testcase.maxPercent
+		ctx.Flags.ForceDelete = testcase.force
+		if testcase.answer != "" {
+			ctx.In = strings.NewReader(testcase.answer)
+		}
+		err := checkDeletes(ctx, removeAll(testcase.removals), testcase.remote)
+		assert.Equal(t, testcase.err, err != nil, "testcase %d", i)
+		if testcase.answer != "" {
+			assert.Contains(t, stdOut.String(), "Remove assets/0.js")
+		}
+	}
+}
+
+func TestDeployConflictReported(t *testing.T) {
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = filepath.Join("_testdata", "projectdir")
+	ctx.Args = []string{"assets/app.js"}
+	ctx.State = &state.Sync{Assets: map[string]state.Entry{"assets/app.js": {Checksum: "last-sync"}}}
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js", Checksum: "edited-online"}}, nil)
+	assert.Nil(t, deploy(ctx))
+	assert.Contains(t, stdOut.String(), "Conflict assets/app.js")
+	client.AssertNotCalled(t, "UpdateAsset", mock.Anything, mock.Anything)
+
+	ctx, _, _, _, _ = createTestCtx()
+	ctx.Flags.OnConflict = "nope"
+	err := deploy(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid --on-conflict value")
+	}
+}
+
+func TestMergeSettingsData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-deploy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "config"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, settingsDataKey), []byte(`{"current": {"color": "red", "logo": "a.png"}}`), 0644))
+
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Env.ManagedSettings = []string{"current.color"}
+	ctx.Flags.Verbose = true
+	ctx.Flags.MergeSettings = true
+	ctx.Args = []string{settingsDataKey}
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: settingsDataKey, Checksum: "remote"}}, nil)
+	client.On("GetAsset", settingsDataKey).Return(shopify.Asset{Key: settingsDataKey, Value: `{"current": {"color": "blue", "logo": "b.png"}}`, Checksum: "remote"}, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool {
+		return a.Key == settingsDataKey && strings.Contains(a.Value, `"color": "red"`) && strings.Contains(a.Value, `"logo": "b.png"`)
+	}), "remote").Return(nil)
+	expectManifest(client)
+	assert.Nil(t, deploy(ctx))
+	assert.Contains(t, stdOut.String(), "Merged config/settings_data.json")
+	client.AssertExpectations(t)
+
+	ctx, client, _, _, stdErr := createTestCtx()
+	ctx.Env.Directory = dir
+	client.On("GetAsset", settingsDataKey).Return(shopify.Asset{Key: settingsDataKey, Value: `nope`, Checksum: "remote"}, nil)
+	mergeSettingsData(ctx)
+	assert.Contains(t, stdErr.String(), "error merging config/settings_data.json: invalid remote settings")
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Directory = dir
+	local, _ := shopify.ReadAsset(ctx.Env, settingsDataKey)
+	client.On("GetAsset", settingsDataKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme)
+	client.On("UpdateAsset", local, "").Return(nil)
+	mergeSettingsData(ctx)
+	client.AssertExpectations(t)
+}
+
 func TestCompileAssetFilenames(t *testing.T) {
-	input := []shopify.Asset{
-		{Key: "assets/app.js"},
-		{Key: "assets/app.scss"},
-		{Key: "assets/foo.js.liquid"},
-		{Key: "assets/app.js.liquid"},
-		{Key: "assets/foo.js"},
+	input := []string{
+		"assets/app.js",
+		"assets/app.scss",
+		"assets/foo.js.liquid",
+		"assets/app.js.liquid",
+		"assets/foo.js",
 	}
 
 	expected := []string{
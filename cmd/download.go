@@ -3,14 +3,25 @@ package cmd
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strconv"
-	"sync"
+	"strings"
+	"sync/atomic"
 
 	"github.com/spf13/cobra"
 
 	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
 	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
+)
+
+// These are the ways download can resolve a file that was changed both locally
+// and on shopify since it was last synced.
+const (
+	conflictStop     = "stop"
+	conflictKeepBoth = "keep-both"
 )
 
 var downloadCmd = &cobra.Command{
@@ -20,6 +31,31 @@ var downloadCmd = &cobra.Command{
  If no filenames are provided then download will download every file in the project
  and write them to disk.
 
+ Files that have changed both locally and on shopify since they were last synced
+ will stop the download, unless they are JSON templates or locales whose changes
+ can be merged. Pass --on-conflict=keep-both to write the version from
+ shopify next to your file as [filename].remote or --on-conflict=overwrite to
+ replace your local changes. A [filename].remote file is ignored while
+ [filename] exists, so remove it once you have merged the changes.
+
+ The planned and completed downloads are recorded in the .themekit directory. If
+ a download does not finish, pass --resume to only download the files that were
+ not downloaded yet.
+
+ The files that failed to download, and why, are recorded in the .themekit
+ directory. Pass --retry-failed to only download those files again.
+
+ Pass --prune to also remove the local files in the theme directories that were
+ synced before but no longer exist on shopify, so that your project mirrors the
+ theme. Files that were never deployed or downloaded are listed and kept, for
+ instance in a new checkout that has no sync state, unless --prune-unsynced is
+ passed as well. Ignored files are never pruned. Pass --dry-run to list what would
+ be downloaded and pruned without changing anything.
+
+ Pass --archive with a file name ending in .zip, .tar.gz or .tgz to write the
+ files into that archive instead of the project directory. Only a single
+ environment can be downloaded into an archive.
+
  For more information, refer to https://shopify.dev/tools/theme-kit/command-reference#download.
  `,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -32,63 +68,173 @@ var downloadCmd = &cobra.Command{
 			}
 			flags.ThemeID = strconv.Itoa(int(theme.ID))
 		}
+		if flags.Archive != "" {
+			return cmdutil.ForSingleClient(flags, args, download)
+		}
 		return cmdutil.ForEachClient(flags, args, download)
 	},
 }
 
 func download(ctx *cmdutil.Ctx) error {
-	var downloadGroup sync.WaitGroup
+	switch ctx.Flags.OnConflict {
+	case "", conflictStop, conflictKeepBoth, conflictOverwrite:
+	default:
+		return fmt.Errorf("[%s] invalid --on-conflict value %q, expected one of %s, %s or %s", colors.Green(ctx.Env.Name), ctx.Flags.OnConflict, conflictStop, conflictKeepBoth, conflictOverwrite)
+	}
+
+	if ctx.Flags.Resume && len(ctx.Args) > 0 {
+		return fmt.Errorf("[%s] file names cannot be passed with --resume", colors.Green(ctx.Env.Name))
+	} else if ctx.Flags.RetryFailed && (ctx.Flags.Resume || len(ctx.Args) > 0) {
+		return fmt.Errorf("[%s] file names and --resume cannot be passed with --retry-failed", colors.Green(ctx.Env.Name))
+	} else if ctx.Flags.Prune && (ctx.Flags.RetryFailed || len(ctx.Args) > 0) {
+		return fmt.Errorf("[%s] file names and --retry-failed cannot be passed with --prune", colors.Green(ctx.Env.Name))
+	} else if ctx.Flags.PruneUnsynced && !ctx.Flags.Prune {
+		return fmt.Errorf("[%s] --prune-unsynced can only be passed with --prune", colors.Green(ctx.Env.Name))
+	}
 
-	assets, err := filesToDownload(ctx)
+	if ctx.Flags.Archive != "" {
+		return downloadArchive(ctx)
+	} else if ctx.Flags.DryRun {
+		// the download is still planned with the sync state but nothing that is
+		// learned while planning it is kept
+		ctx.State.KeepInMemory()
+	}
+
+	tasks, journal, err := startDownload(ctx)
 	if err != nil {
 		return err
 	}
+	defer journal.Close()
+
+	if ctx.Flags.DryRun {
+		reportDryRun(ctx, tasks)
+		return nil
+	}
+	defer saveFailures(ctx, "download")
+
+	var failures int32
+	ctx.StartProgress(len(tasks))
+	ctx.Run(tasks, func(task cmdutil.Task) file.Op {
+		op, err := downloadAsset(ctx, task.Path, task.Op)
+		if err != nil {
+			atomic.AddInt32(&failures, 1)
+			ctx.Failed(task.Path, err)
+		} else {
+			journal.Done(task.Path)
+		}
+		return op
+	})
+
+	if failures == 0 {
+		journal.Finish()
+	}
+	return nil
+}
+
+// startDownload will plan a new download and start its journal or, with the
+// resume flag, plan the operations that an unfinished download did not complete
+func startDownload(ctx *cmdutil.Ctx) ([]cmdutil.Task, *state.Journal, error) {
+	tasks := []cmdutil.Task{}
+	if ctx.Flags.Resume {
+		journal, err := ctx.State.ResumeJournal("download")
+		if err == state.ErrNoJournal {
+			return tasks, nil, fmt.Errorf("[%s] there is no unfinished download to resume", colors.Green(ctx.Env.Name))
+		} else if err != nil {
+			return tasks, nil, fmt.Errorf("[%s] could not read the unfinished download: %s", colors.Green(ctx.Env.Name), err)
+		}
+		for _, step := range journal.Remaining() {
+			for _, entry := range step {
+				tasks = append(tasks, cmdutil.Task{Path: entry.Path, Op: entry.Op})
+			}
+		}
+		return tasks, journal, nil
+	}
+
+	patterns := ctx.Args
+	if ctx.Flags.RetryFailed {
+		var err error
+		if patterns, err = failedPaths(ctx, "download"); err != nil {
+			return tasks, nil, err
+		}
+	}
+	assets, err := filesToDownload(ctx, patterns)
+	if err != nil {
+		return tasks, nil, err
+	}
 
 	if len(assets) == 0 {
-		return fmt.Errorf("No files to download")
+		return tasks, nil, fmt.Errorf("No files to download")
 	}
 
-	ctx.StartProgress(len(assets))
-	for asset, op := range assets {
-		downloadGroup.Add(1)
-		go func(path string, op file.Op) {
-			defer downloadGroup.Done()
-			perform(ctx, path, op, "")
-		}(asset, op)
+	if conflicts := conflictedPaths(ctx, assets); len(conflicts) > 0 {
+		return tasks, nil, fmt.Errorf(
+			"[%s] these files have changed both locally and on shopify since they were last synced:\n\t%s\nUse --on-conflict=%s to write the version on shopify next to them as [filename]%s or --on-conflict=%s to replace your local changes",
+			colors.Green(ctx.Env.Name),
+			strings.Join(conflicts, "\n\t"),
+			conflictKeepBoth,
+			file.RemoteCopyExt,
+			conflictOverwrite,
+		)
 	}
 
-	downloadGroup.Wait()
+	entries := []state.JournalEntry{}
+	for path, op := range assets {
+		tasks = append(tasks, cmdutil.Task{Path: path, Op: op})
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Path < tasks[j].Path })
+	if ctx.Flags.DryRun {
+		return tasks, nil, nil
+	}
+	for _, task := range tasks {
+		entries = append(entries, state.JournalEntry{Path: task.Path, Op: task.Op})
+	}
+	journal, err := ctx.State.StartJournal("download", [][]state.JournalEntry{entries})
+	if err != nil {
+		ctx.ErrLog.Printf("[%s] could not record the download plan, it will not be possible to resume this download: %s", colors.Green(ctx.Env.Name), err)
+	}
+	return tasks, journal, nil
+}
 
-	return nil
+// downloadAsset will carry out a single planned file operation and return the
+// file operation that was actually carried out
+func downloadAsset(ctx *cmdutil.Ctx, path string, op file.Op) (file.Op, error) {
+	switch op {
+	case file.Merge:
+		return mergeAsset(ctx, path, false)
+	case file.Conflict:
+		// the conflict is kept for the user to merge by hand, it is not reported
+		// the way a file that changed on shopify during a deploy is
+		if err := downloadRemoteCopy(ctx, path); err != nil {
+			return op, err
+		}
+		ctx.KeptBoth(path)
+		return op, nil
+	}
+	return op, perform(ctx, path, op, "")
 }
 
-func filesToDownload(ctx *cmdutil.Ctx) (map[string]file.Op, error) {
+func filesToDownload(ctx *cmdutil.Ctx, patterns []string) (map[string]file.Op, error) {
 	fetchableFiles := map[string]file.Op{}
 
 	assets, err := ctx.Client.GetAllAssets()
 	if err != nil {
 		return fetchableFiles, err
 	}
+	ctx.State.Listed()
 
-	if len(ctx.Args) <= 0 {
+	if len(patterns) <= 0 {
 		for _, asset := range assets {
 			fetchableFiles[asset.Key] = downloadFileAction(ctx, asset)
 		}
+		if ctx.Flags.Prune {
+			return fetchableFiles, addPrunedFiles(ctx, fetchableFiles)
+		}
 		return fetchableFiles, nil
 	}
 
 	for _, asset := range assets {
-		for _, pattern := range ctx.Args {
-			// These need to be converted to platform specific because filepath.Match
-			// uses platform specific separators
-			pattern = filepath.FromSlash(pattern)
-			filename := filepath.FromSlash(asset.Key)
-			globMatched, _ := filepath.Match(pattern, filename)
-			dirMatched, _ := filepath.Match(pattern+string(filepath.Separator)+"*", filename)
-			fileMatched := filename == pattern
-			if globMatched || dirMatched || fileMatched {
-				fetchableFiles[asset.Key] = downloadFileAction(ctx, asset)
-			}
+		if matchesPatterns(asset.Key, patterns) {
+			fetchableFiles[asset.Key] = downloadFileAction(ctx, asset)
 		}
 	}
 
@@ -99,13 +245,144 @@ func filesToDownload(ctx *cmdutil.Ctx) (map[string]file.Op, error) {
 	return fetchableFiles, nil
 }
 
+// matchesPatterns will return true if the key is one of the file names, or is
+// matched by one of the globs or directories, passed to download
+func matchesPatterns(key string, patterns []string) bool {
+	// These need to be converted to platform specific because filepath.Match
+	// uses platform specific separators
+	filename := filepath.FromSlash(key)
+	for _, pattern := range patterns {
+		pattern = filepath.FromSlash(pattern)
+		globMatched, _ := filepath.Match(pattern, filename)
+		dirMatched, _ := filepath.Match(pattern+string(filepath.Separator)+"*", filename)
+		if globMatched || dirMatched || filename == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// addPrunedFiles will plan to remove every local file in the theme directories
+// that was synced with shopify but is no longer on it, so that the project
+// directory mirrors the theme. Local files that were never synced may be new work
+// that has not been deployed yet, so they are listed and kept unless the prune
+// unsynced flag was passed.
+func addPrunedFiles(ctx *cmdutil.Ctx, fetchableFiles map[string]file.Op) error {
+	keys, err := shopify.AssetKeys(ctx.Env)
+	if err != nil {
+		return err
+	}
+	unsynced := []string{}
+	for _, key := range keys {
+		if _, onRemote := fetchableFiles[key]; onRemote || !file.IsAssetKey(key) {
+			continue
+		} else if _, synced := ctx.State.Checksum(key); synced || ctx.Flags.PruneUnsynced {
+			fetchableFiles[key] = file.Prune
+		} else {
+			unsynced = append(unsynced, key)
+		}
+	}
+	if len(unsynced) > 0 {
+		ctx.Log.Printf(
+			"[%s] these files are not on shopify but were never deployed or downloaded so they are kept, pass --prune-unsynced to prune them too:\n\t%s",
+			colors.Green(ctx.Env.Name),
+			strings.Join(unsynced, "\n\t"),
+		)
+	}
+	return nil
+}
+
+// reportDryRun will print what download would do to the project directory without
+// doing any of it
+func reportDryRun(ctx *cmdutil.Ctx, tasks []cmdutil.Task) {
+	changes := 0
+	for _, task := range tasks {
+		switch task.Op {
+		case file.Get:
+			ctx.Log.Printf("[%s] Would download %s", colors.Green(ctx.Env.Name), colors.Blue(task.Path))
+		case file.Merge:
+			ctx.Log.Printf("[%s] Would merge %s", colors.Green(ctx.Env.Name), colors.Blue(task.Path))
+		case file.Conflict:
+			ctx.Log.Printf("[%s] Would write %s next to %s", colors.Green(ctx.Env.Name), colors.Blue(task.Path+file.RemoteCopyExt), colors.Blue(task.Path))
+		case file.Prune:
+			ctx.Log.Printf("[%s] Would prune %s", colors.Green(ctx.Env.Name), colors.Yellow(task.Path))
+		default:
+			continue
+		}
+		changes++
+	}
+	ctx.Log.Printf("[%s] %d of %d files would change, nothing was written because of --dry-run", colors.Green(ctx.Env.Name), changes, len(tasks))
+}
+
 func downloadFileAction(ctx *cmdutil.Ctx, asset shopify.Asset) file.Op {
-	op := file.Get
 	if asset.Checksum == "" {
-		return op
+		return file.Get
+	}
+
+	localChecksum, err := shopify.ReadChecksum(ctx.Env, ctx.State, asset.Key)
+	if err != nil {
+		return file.Get
+	} else if asset.Checksum == localChecksum {
+		recordLocalSync(ctx, asset.Key, localChecksum)
+		return file.Skip
+	}
+
+	if ctx.State.FirstSync() {
+		// nothing has been synced yet so shopify is taken as the latest version,
+		// the way download worked before changes were tracked
+		return file.Get
+	}
+
+	lastChecksum, synced := ctx.State.Checksum(asset.Key)
+	if synced && localChecksum == lastChecksum {
+		// only changed on shopify
+		return file.Get
+	} else if synced && asset.Checksum == lastChecksum {
+		// only changed locally, these changes have not been deployed yet
+		return file.Skip
 	}
-	if localAsset, _ := shopify.ReadAsset(ctx.Env, asset.Key); asset.Checksum == localAsset.Checksum {
-		op = file.Skip
+	return downloadConflictAction(ctx, asset.Key)
+}
+
+func downloadConflictAction(ctx *cmdutil.Ctx, path string) file.Op {
+	if ctx.Flags.OnConflict == conflictOverwrite {
+		return file.Get
+	} else if canMerge(ctx, path) {
+		return file.Merge
+	}
+	return file.Conflict
+}
+
+// conflictedPaths will return the sorted paths of all the conflicting files if
+// the download should stop because of them.
+func conflictedPaths(ctx *cmdutil.Ctx, assets map[string]file.Op) []string {
+	conflicts := []string{}
+	if ctx.Flags.OnConflict == conflictKeepBoth {
+		return conflicts
 	}
-	return op
+	for path, op := range assets {
+		if op == file.Conflict {
+			conflicts = append(conflicts, path)
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// downloadRemoteCopy will write the remote version of a file next to the local
+// version so that the changes can be merged by hand.
+func downloadRemoteCopy(ctx *cmdutil.Ctx, path string) error {
+	asset, err := ctx.Client.GetAsset(path)
+	if err != nil {
+		ctx.Err("[%s] error downloading %s: %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+		return err
+	}
+	asset.Key += file.RemoteCopyExt
+	if err = asset.Write(ctx.Env.Directory); err != nil {
+		ctx.Err("[%s] error writing %s: %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key), err)
+		return err
+	} else if ctx.Flags.Verbose {
+		ctx.Log.Printf("[%s] Successfully wrote %s to disk", colors.Green(ctx.Env.Name), colors.Blue(asset.Key))
+	}
+	return nil
 }
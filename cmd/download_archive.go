@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/themekit/src/archive"
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/file"
+)
+
+// downloadArchive will stream the theme files into the archive passed with the
+// archive flag instead of writing them to the project directory
+func downloadArchive(ctx *cmdutil.Ctx) error {
+	if ctx.Flags.Prune || ctx.Flags.Resume || ctx.Flags.RetryFailed || ctx.Flags.DryRun {
+		return fmt.Errorf("[%s] --prune, --resume, --retry-failed and --dry-run cannot be passed with --archive", colors.Green(ctx.Env.Name))
+	}
+
+	assets, err := ctx.Client.GetAllAssets()
+	if err != nil {
+		return err
+	}
+	tasks := []cmdutil.Task{}
+	for _, asset := range assets {
+		if len(ctx.Args) == 0 || matchesPatterns(asset.Key, ctx.Args) {
+			tasks = append(tasks, cmdutil.Task{Path: asset.Key, Op: file.Get})
+		}
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("No files to download")
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Path < tasks[j].Path })
+
+	writer, err := archive.Create(ctx.Flags.Archive)
+	if err != nil {
+		return fmt.Errorf("[%s] could not create %s: %s", colors.Green(ctx.Env.Name), ctx.Flags.Archive, err)
+	}
+
+	ctx.StartProgress(len(tasks))
+	ctx.Run(tasks, func(task cmdutil.Task) file.Op {
+		asset, err := ctx.Client.GetAsset(task.Path)
+		if err != nil {
+			ctx.Err("[%s] error downloading %s: %s", colors.Green(ctx.Env.Name), colors.Blue(task.Path), err)
+			return task.Op
+		}
+		contents, err := asset.Contents()
+		if err == nil {
+			err = writer.Add(asset.Key, contents)
+		}
+		if err != nil {
+			ctx.Err("[%s] error writing %s to %s: %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key), ctx.Flags.Archive, err)
+			return task.Op
+		}
+		if ctx.Flags.Verbose {
+			ctx.Log.Printf("[%s] Successfully wrote %s to %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key), ctx.Flags.Archive)
+		}
+		return task.Op
+	})
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("[%s] could not write %s: %s", colors.Green(ctx.Env.Name), ctx.Flags.Archive, err)
+	}
+	return nil
+}
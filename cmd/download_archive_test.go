@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+func TestDownloadArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "theme.zip")
+
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Flags.Archive = path
+	ctx.Args = []string{"assets"}
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js"}, {Key: "assets/logo.png"}, {Key: "layout/theme.liquid"}}, nil)
+	client.On("GetAsset", "assets/app.js").Return(shopify.Asset{Key: "assets/app.js", Value: "var a;"}, nil)
+	client.On("GetAsset", "assets/logo.png").Return(shopify.Asset{Key: "assets/logo.png", Attachment: base64.StdEncoding.EncodeToString([]byte("png"))}, nil)
+	assert.Nil(t, download(ctx))
+	client.AssertNotCalled(t, "GetAsset", "layout/theme.liquid")
+
+	r, err := zip.OpenReader(path)
+	assert.Nil(t, err)
+	defer r.Close()
+	found := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		assert.Nil(t, err)
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		found[f.Name] = string(data)
+	}
+	assert.Equal(t, map[string]string{"assets/app.js": "var a;", "assets/logo.png": "png"}, found)
+
+	ctx, client, _, _, stdErr := createTestCtx()
+	ctx.Flags.Archive = filepath.Join(dir, "theme.tar.gz")
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js"}}, nil)
+	client.On("GetAsset", mock.Anything).Return(shopify.Asset{}, fmt.Errorf("server error"))
+	assert.Nil(t, download(ctx))
+	assert.Contains(t, stdErr.String(), "error downloading assets/app.js")
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Flags.Archive = filepath.Join(dir, "theme.rar")
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js"}}, nil)
+	err = download(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unsupported archive format")
+	}
+
+	ctx, _, _, _, _ = createTestCtx()
+	ctx.Flags.Archive = path
+	ctx.Flags.Prune = true
+	assert.NotNil(t, download(ctx))
+}
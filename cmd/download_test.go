@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,6 +12,7 @@ import (
 
 	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
 )
 
 func TestDownload(t *testing.T) {
@@ -71,7 +75,7 @@ func TestFilesToDownload(t *testing.T) {
 		ctx, client, _, _, _ := createTestCtx()
 		ctx.Args = testcase.args
 		client.On("GetAllAssets").Return(allAssets, testcase.respErr)
-		filenames, err := filesToDownload(ctx)
+		filenames, err := filesToDownload(ctx, ctx.Args)
 		assert.Equal(t, testcase.ret, filenames, fmt.Sprintf("Failed to compare filenames in test case %d", i))
 		if testcase.err == "" {
 			assert.Nil(t, err)
@@ -95,3 +99,215 @@ func TestFilesToDownloadFileAction(t *testing.T) {
 	op = downloadFileAction(ctx, shopify.Asset{Key: "assets/app.js"})
 	assert.Equal(t, file.Get, op)
 }
+
+func TestDownloadFileActionSinceLastSync(t *testing.T) {
+	ctx, _, _, _, _ := createTestCtx()
+	ctx.Env.Directory = "_testdata/projectdir"
+	localAsset, _ := shopify.ReadAsset(ctx.Env, "assets/app.js")
+
+	ctx.State = &state.Sync{Assets: map[string]state.Entry{"assets/app.js": {Checksum: localAsset.Checksum}}}
+	op := downloadFileAction(ctx, shopify.Asset{Key: "assets/app.js", Checksum: "changed online"})
+	assert.Equal(t, file.Get, op)
+
+	op = downloadFileAction(ctx, shopify.Asset{Key: "assets/nope.js", Checksum: "not correct"})
+	assert.Equal(t, file.Get, op)
+
+	ctx.State = &state.Sync{Assets: map[string]state.Entry{"assets/app.js": {Checksum: "last sync"}}}
+	op = downloadFileAction(ctx, shopify.Asset{Key: "assets/app.js", Checksum: "last sync"})
+	assert.Equal(t, file.Skip, op)
+
+	op = downloadFileAction(ctx, shopify.Asset{Key: "assets/app.js", Checksum: "changed online"})
+	assert.Equal(t, file.Conflict, op)
+
+	ctx.Flags.OnConflict = conflictOverwrite
+	op = downloadFileAction(ctx, shopify.Asset{Key: "assets/app.js", Checksum: "changed online"})
+	assert.Equal(t, file.Get, op)
+}
+
+func TestDownloadConflicts(t *testing.T) {
+	synced := map[string]state.Entry{"assets/app.js": {Checksum: "last sync"}}
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.Directory = "_testdata/projectdir"
+	ctx.State = &state.Sync{Assets: synced}
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js", Checksum: "changed online"}}, nil)
+	err := download(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "assets/app.js")
+		assert.Contains(t, err.Error(), "--on-conflict=keep-both")
+	}
+	client.AssertNotCalled(t, "GetAsset", mock.Anything)
+
+	ctx, client, _, _, stdErr := createTestCtx()
+	ctx.Env.Directory = "_testdata/projectdir"
+	ctx.State = &state.Sync{Assets: synced}
+	ctx.Flags.OnConflict = conflictKeepBoth
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js", Checksum: "changed online"}}, nil)
+	client.On("GetAsset", "assets/app.js").Return(shopify.Asset{}, fmt.Errorf("asset err"))
+	assert.Nil(t, download(ctx))
+	assert.Contains(t, stdErr.String(), "error downloading assets/app.js")
+
+	dir := createThemeDir(t, map[string]string{"assets/app.js": `mine`})
+	defer os.RemoveAll(dir)
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.State = &state.Sync{Assets: synced}
+	ctx.Flags.OnConflict = conflictKeepBoth
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "assets/app.js", Checksum: "changed online"}}, nil)
+	client.On("GetAsset", "assets/app.js").Return(shopify.Asset{Key: "assets/app.js", Value: "theirs"}, nil)
+	assert.Nil(t, download(ctx))
+	assert.Contains(t, stdOut.String(), "Kept both versions of assets/app.js")
+	assert.NotContains(t, stdOut.String(), "Changed on shopify since the last sync")
+	contents, _ := ioutil.ReadFile(filepath.Join(dir, "assets", "app.js"))
+	assert.Equal(t, "mine", string(contents))
+	contents, _ = ioutil.ReadFile(filepath.Join(dir, "assets", "app.js"+file.RemoteCopyExt))
+	assert.Equal(t, "theirs", string(contents))
+
+	ctx, _, _, _, _ = createTestCtx()
+	ctx.Flags.OnConflict = "nope"
+	err = download(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid --on-conflict value")
+	}
+}
+
+func TestDownloadRetryFailed(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{})
+	defer os.RemoveAll(dir)
+	allAssets := []shopify.Asset{{Key: "assets/app.js"}, {Key: "snippets/card.liquid"}}
+
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.State, _ = state.Load(ctx.Env)
+	client.On("GetAllAssets").Return(allAssets, nil)
+	client.On("GetAsset", "assets/app.js").Return(shopify.Asset{Key: "assets/app.js", Value: "app"}, nil).Once()
+	client.On("GetAsset", "snippets/card.liquid").Return(shopify.Asset{}, fmt.Errorf("server error")).Once()
+	assert.Nil(t, download(ctx))
+	failures, err := ctx.State.Failures("download")
+	assert.Nil(t, err)
+	assert.Equal(t, []state.Failure{{Path: "snippets/card.liquid", Cause: "server error"}}, failures)
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.State, _ = state.Load(ctx.Env)
+	ctx.Flags.RetryFailed = true
+	client.On("GetAllAssets").Return(allAssets, nil)
+	client.On("GetAsset", "snippets/card.liquid").Return(shopify.Asset{Key: "snippets/card.liquid", Value: "card"}, nil).Once()
+	assert.Nil(t, download(ctx))
+	client.AssertExpectations(t)
+
+	err = download(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "there are no failed files to retry")
+	}
+}
+
+func TestDownloadPrune(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"assets/app.js":           `app`,
+		"templates/index.json":    `{}`,
+		"snippets/stale.liquid":   `stale`,
+		"snippets/ignored.liquid": `ignored`,
+		"snippets/new.liquid":     `never deployed`,
+		"README.md":               `readme`,
+		".themekit/development/state.json": `{"assets": {
+			"assets/app.js": {"checksum": "last sync"},
+			"snippets/stale.liquid": {"checksum": "last sync"},
+			"snippets/ignored.liquid": {"checksum": "last sync"}
+		}}`,
+	})
+	defer os.RemoveAll(dir)
+	staleFile := filepath.Join(dir, "snippets", "stale.liquid")
+	remoteAssets := []shopify.Asset{
+		{Key: "assets/app.js", Checksum: "changed online"},
+		shopify.NewAsset("templates/index.json", []byte(`{}`)),
+	}
+	stateDir := readDir(t, filepath.Join(dir, ".themekit"))
+
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.Env.IgnoredFiles = []string{"snippets/ignored.liquid"}
+	ctx.State, _ = state.Load(ctx.Env)
+	ctx.Flags.Prune = true
+	ctx.Flags.DryRun = true
+	ctx.Flags.OnConflict = conflictOverwrite
+	client.On("GetAllAssets").Return(remoteAssets, nil)
+	assert.Nil(t, download(ctx))
+	assert.Nil(t, ctx.State.Save())
+	assert.Contains(t, stdOut.String(), "Would download assets/app.js")
+	assert.Contains(t, stdOut.String(), "Would prune snippets/stale.liquid")
+	assert.NotContains(t, stdOut.String(), "README.md")
+	assert.NotContains(t, stdOut.String(), "ignored.liquid")
+	// files that were never synced are listed but not pruned
+	assert.NotContains(t, stdOut.String(), "Would prune snippets/new.liquid")
+	assert.Contains(t, stdOut.String(), "never deployed or downloaded so they are kept, pass --prune-unsynced to prune them too:\n\tsnippets/new.liquid")
+	assert.Contains(t, stdOut.String(), "2 of 3 files would change")
+	client.AssertNotCalled(t, "GetAsset", mock.Anything)
+	_, err := os.Stat(staleFile)
+	assert.Nil(t, err)
+	// a dry run does not record anything that it learned
+	assert.Equal(t, stateDir, readDir(t, filepath.Join(dir, ".themekit")))
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.Env.IgnoredFiles = []string{"snippets/ignored.liquid"}
+	ctx.State, _ = state.Load(ctx.Env)
+	ctx.Flags.Prune = true
+	ctx.Flags.OnConflict = conflictOverwrite
+	client.On("GetAllAssets").Return(remoteAssets, nil)
+	client.On("GetAsset", "assets/app.js").Return(shopify.Asset{Key: "assets/app.js", Value: "new"}, nil).Once()
+	assert.Nil(t, download(ctx))
+	client.AssertExpectations(t)
+	_, err = os.Stat(staleFile)
+	assert.True(t, os.IsNotExist(err))
+	for _, kept := range []string{"README.md", "snippets/ignored.liquid", "snippets/new.liquid", ".themekit/development/state.json"} {
+		_, err = os.Stat(filepath.Join(dir, filepath.FromSlash(kept)))
+		assert.Nil(t, err, kept)
+	}
+
+	// files that were never synced are pruned too when asked
+	ctx, client, _, stdOut, _ = createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.Env.IgnoredFiles = []string{"snippets/ignored.liquid"}
+	ctx.State, _ = state.Load(ctx.Env)
+	ctx.Flags.Prune = true
+	ctx.Flags.PruneUnsynced = true
+	ctx.Flags.DryRun = true
+	ctx.Flags.OnConflict = conflictOverwrite
+	client.On("GetAllAssets").Return(remoteAssets, nil)
+	assert.Nil(t, download(ctx))
+	assert.Contains(t, stdOut.String(), "Would prune snippets/new.liquid")
+	assert.NotContains(t, stdOut.String(), "ignored.liquid")
+	assert.NotContains(t, stdOut.String(), "README.md")
+
+	ctx, _, _, _, _ = createTestCtx()
+	ctx.Flags.Prune = true
+	ctx.Args = []string{"assets/app.js"}
+	assert.NotNil(t, download(ctx))
+
+	ctx, _, _, _, _ = createTestCtx()
+	ctx.Flags.PruneUnsynced = true
+	err = download(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "--prune-unsynced can only be passed with --prune")
+	}
+}
+
+// readDir will return the contents of every file in the directory by their path
+func readDir(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		files[path] = string(data)
+		return err
+	})
+	assert.Nil(t, err)
+	return files
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show what was last deployed to a theme",
+	Long: `Info will read the manifest that deploy records on the theme and show when
+ the theme was last deployed, by whom, from which git commit and with which
+ version of themekit. Pass --verbose to also list the files that were changed.
+
+ The manifest is public, so deploys are recorded under the deployer from your
+ config, or the account that started a CI build, and never your user or host.
+ Without either an anonymous id is recorded that is the same for every deploy
+ from the same user and computer.
+ `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// info only reads from the theme
+		flags.AllowLive = true
+		return cmdutil.ForEachClient(flags, args, info)
+	},
+}
+
+func info(ctx *cmdutil.Ctx) error {
+	ctx.DisableSummary()
+
+	manifest, found, err := readManifest(ctx)
+	if err != nil {
+		return err
+	} else if !found {
+		ctx.Log.Printf("[%s] no deploy has been recorded on theme %s", colors.Green(ctx.Env.Name), colors.Yellow(ctx.Env.ThemeID))
+		return nil
+	}
+
+	commit := manifest.GitCommit
+	if commit == "" {
+		commit = "unknown"
+	}
+	ctx.Log.Printf("[%s] theme %s was last deployed at %s", colors.Green(ctx.Env.Name), colors.Yellow(ctx.Env.ThemeID), manifest.DeployedAt.Local().Format(time.RFC1123))
+	ctx.Log.Printf("  by:       %s", manifest.DeployedBy)
+	ctx.Log.Printf("  commit:   %s", colors.Yellow(commit))
+	ctx.Log.Printf("  themekit: %s", manifest.ThemeKitVersion)
+	ctx.Log.Printf("  updated:  %d files", len(manifest.Updated))
+	ctx.Log.Printf("  removed:  %d files", len(manifest.Removed))
+	if ctx.Flags.Verbose {
+		for _, key := range manifest.Updated {
+			ctx.Log.Printf("    %s %s", colors.Cyan("Updated"), colors.Blue(key))
+		}
+		for _, key := range manifest.Removed {
+			ctx.Log.Printf("    %s %s", colors.Red("Removed"), colors.Blue(key))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Shopify/themekit/src/check"
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+var localesCmd = &cobra.Command{
+	Use:   "locales",
+	Short: "Work with the locales of the theme",
+}
+
+var localesCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Compare the locales of the theme to the default locale",
+	Long: `Check will compare every locale in the locales directory to the default
+ locale, like locales/en.default.json, and every schema locale to the default
+ schema locale, like locales/en.default.schema.json. It reports the translations
+ that each locale is missing, and the ones it has that the default does not.
+
+ The liquid files are scanned for translations used with the t filter, like
+ {{ 'general.title' | t }}, and the schemas for translations like
+ "t:sections.header.name", to report the translations that are used but are not
+ in the default locale, and the ones in the default locale that are not used.
+ Translations with names that are built in liquid cannot be found, so check unused
+ translations before deleting them.
+
+ Pass --json to print the results as json. Check exits with an error if a locale
+ is missing translations or a translation that is used is not in the default
+ locale.
+
+ Check does not connect to shopify so your config does not need a password,
+ theme id or store.
+ `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmdutil.ForLocalEnv(flags, args, checkLocales)
+	},
+}
+
+func checkLocales(ctx *cmdutil.Ctx) error {
+	keys, err := shopify.AssetKeys(ctx.Env)
+	if err != nil {
+		return err
+	}
+	report, err := check.Locales(keys, func(key string) ([]byte, error) {
+		asset, err := shopify.ReadAsset(ctx.Env, key)
+		if err != nil {
+			return nil, err
+		}
+		return asset.Contents()
+	})
+	if err != nil {
+		return fmt.Errorf("[%s] could not check the locales: %s", colors.Green(ctx.Env.Name), err)
+	}
+
+	if ctx.Flags.JSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		ctx.Log.Print(string(data))
+	} else {
+		for _, problem := range report.Problems() {
+			printProblem(ctx, problem)
+		}
+	}
+
+	missing := len(report.Undefined)
+	for _, locale := range report.Locales {
+		missing += len(locale.Missing)
+	}
+	if missing > 0 {
+		return fmt.Errorf("[%s] found %d missing translations", colors.Green(ctx.Env.Name), missing)
+	}
+	if !ctx.Flags.JSON {
+		ctx.Log.Printf("[%s] No missing translations in %d locales", colors.Green(ctx.Env.Name), len(report.Locales)+1)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/check"
+)
+
+func TestCheckLocales(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"locales/en.default.json": `{"general": {"title": "Title", "old": "Old"}}`,
+		"locales/fr.json":         `{"general": {"old": "Vieux"}}`,
+		"layout/theme.liquid":     `{{ 'general.title' | t }}`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, _, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	err := checkLocales(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "found 1 missing translations")
+	}
+	assert.Contains(t, stdOut.String(), filepath.Join("locales", "fr.json")+": missing general.title, which is in locales/en.default.json")
+	assert.Contains(t, stdOut.String(), filepath.Join("locales", "en.default.json")+": general.old is not used by any theme file")
+
+	stdOut.Reset()
+	ctx.Flags.JSON = true
+	assert.NotNil(t, checkLocales(ctx))
+	var report check.LocaleReport
+	assert.Nil(t, json.Unmarshal(stdOut.Bytes(), &report))
+	assert.Equal(t, "locales/en.default.json", report.Default)
+	assert.Equal(t, []string{"general.title"}, report.Locales[0].Missing)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "locales", "fr.json"), []byte(`{"general": {"title": "Titre", "old": "Vieux"}}`), 0644))
+	stdOut.Reset()
+	ctx.Flags.JSON = false
+	assert.Nil(t, checkLocales(ctx))
+	assert.Contains(t, stdOut.String(), "No missing translations in 2 locales")
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "locales", "en.default.json")))
+	err = checkLocales(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "there is no default locale")
+	}
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+const (
+	// lockTTL is how long a lock is held for if it is not refreshed, so that a
+	// process that was killed does not keep a theme locked for long
+	lockTTL = 5 * time.Minute
+	// lockRefresh is how often a held lock has its expiry extended
+	lockRefresh = 2 * time.Minute
+)
+
+// lockSettle is how long to wait before checking that a new lock was not replaced
+// by another process that created it at the same time
+var lockSettle = time.Second
+
+// themeLock is what is stored in the lock asset on shopify. The asset is served
+// publicly so the holder is only identified by a hash of the user and host, and
+// the owner is only set from the deployer in the config or CI.
+type themeLock struct {
+	Token     string    `json:"token"`
+	Holder    string    `json:"holder"`
+	Owner     string    `json:"owner,omitempty"`
+	Command   string    `json:"command"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// heldLock is a lock on a theme that is held by this process
+type heldLock struct {
+	ctx      *cmdutil.Ctx
+	lock     themeLock
+	mu       sync.Mutex
+	checksum string
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// withLock will run the command while holding the lock on the theme of the
+// environment, so that two commands do not change the same theme at once
+func withLock(command string, run func(ctx *cmdutil.Ctx) error) func(ctx *cmdutil.Ctx) error {
+	return func(ctx *cmdutil.Ctx) error {
+		if ctx.Env.ReadOnly {
+			return run(ctx)
+		}
+		held, err := acquireLock(ctx, command, lockRefresh)
+		if err != nil {
+			return err
+		}
+		defer held.release()
+		return run(ctx)
+	}
+}
+
+// acquireLock will lock the theme, failing if it is already locked by someone
+// else unless the lock has expired or the steal lock flag was passed. The lock is
+// refreshed at the interval provided until it is released.
+func acquireLock(ctx *cmdutil.Ctx, command string, refresh time.Duration) (*heldLock, error) {
+	current, checksum, err := readLock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] could not read the theme lock: %s", colors.Green(ctx.Env.Name), err)
+	} else if checksum != "" && time.Now().Before(current.ExpiresAt) && !ctx.Flags.StealLock {
+		return nil, lockedError(ctx, current)
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	held := &heldLock{
+		ctx:      ctx,
+		lock:     themeLock{Token: token, Holder: lockHolder(), Owner: deployerName(ctx.Env), Command: command},
+		checksum: checksum,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := held.write(); err != nil {
+		return nil, fmt.Errorf("[%s] could not lock the theme: %s", colors.Green(ctx.Env.Name), err)
+	}
+
+	if checksum == "" {
+		// shopify cannot create an asset only if it does not exist, so another
+		// process may have created the lock at the same time and replaced this one
+		time.Sleep(lockSettle)
+		current, _, err := readLock(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] could not read the theme lock: %s", colors.Green(ctx.Env.Name), err)
+		} else if current.Token == "" {
+			return nil, fmt.Errorf("[%s] could not lock the theme, the lock was removed while it was being taken", colors.Green(ctx.Env.Name))
+		} else if current.Token != held.lock.Token {
+			return nil, lockedError(ctx, current)
+		}
+	}
+
+	go held.keepAlive(refresh)
+	return held, nil
+}
+
+// readLock will return the lock on the theme and its checksum, which is empty if
+// the theme is not locked
+func readLock(ctx *cmdutil.Ctx) (themeLock, string, error) {
+	var lock themeLock
+	asset, err := ctx.Client.GetAsset(file.LockKey)
+	if err == shopify.ErrNotPartOfTheme {
+		return lock, "", nil
+	} else if err != nil {
+		return lock, "", err
+	}
+	if err := json.Unmarshal([]byte(asset.Value), &lock); err != nil {
+		// a lock that cannot be read is treated as expired so that it can be replaced
+		return themeLock{}, asset.Checksum, nil
+	}
+	return lock, asset.Checksum, nil
+}
+
+// write will store the lock with a new expiry, only replacing the lock that was
+// last read or written by this process
+func (held *heldLock) write() error {
+	held.mu.Lock()
+	defer held.mu.Unlock()
+	held.lock.ExpiresAt = time.Now().Add(lockTTL)
+	data, err := json.Marshal(held.lock)
+	if err != nil {
+		return err
+	}
+	asset := shopify.NewAsset(file.LockKey, data)
+	if err := held.ctx.Client.UpdateAsset(asset, held.checksum); err != nil {
+		return err
+	}
+	held.checksum = asset.Checksum
+	return nil
+}
+
+func (held *heldLock) keepAlive(refresh time.Duration) {
+	defer close(held.done)
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := held.write(); err != nil {
+				held.ctx.ErrLog.Printf("[%s] could not refresh the theme lock: %s", colors.Green(held.ctx.Env.Name), err)
+			}
+		case <-held.stop:
+			return
+		}
+	}
+}
+
+// release will stop refreshing the lock and remove it from shopify if it is still
+// held by this process
+func (held *heldLock) release() {
+	close(held.stop)
+	<-held.done
+
+	current, _, err := readLock(held.ctx)
+	if err != nil || current.Token != held.lock.Token {
+		return
+	}
+	if err := held.ctx.Client.DeleteAsset(shopify.Asset{Key: file.LockKey}); err != nil {
+		held.ctx.ErrLog.Printf("[%s] could not remove the theme lock: %s", colors.Green(held.ctx.Env.Name), err)
+	}
+}
+
+func lockedError(ctx *cmdutil.Ctx, lock themeLock) error {
+	holder := "another process"
+	if lock.Owner != "" {
+		holder = lock.Owner
+	}
+	if lock.Holder == lockHolder() {
+		holder += " on this computer"
+	}
+	return fmt.Errorf(
+		"[%s] theme is locked by %s running %s until %s. If that lock is stale, pass --steal-lock to take it",
+		colors.Green(ctx.Env.Name),
+		colors.Yellow(holder),
+		colors.Yellow(lock.Command),
+		lock.ExpiresAt.Local().Format(time.Kitchen),
+	)
+}
+
+// lockHolder will identify the user and computer that holds a lock without
+// revealing either of them
+func lockHolder() string {
+	host, _ := os.Hostname()
+	sum := sha256.Sum256([]byte(lockOwner() + "@" + host))
+	return hex.EncodeToString(sum[:8])
+}
+
+func lockOwner() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func newLockToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/cmdutil/_mocks"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+func lockAsset(t *testing.T, lock themeLock) shopify.Asset {
+	data, err := json.Marshal(lock)
+	assert.Nil(t, err)
+	return shopify.NewAsset(file.LockKey, data)
+}
+
+func isLock(asset shopify.Asset) bool {
+	return asset.Key == file.LockKey
+}
+
+// withoutLockSettle will not wait for a new lock to settle while testing
+func withoutLockSettle() func() {
+	settle := lockSettle
+	lockSettle = 0
+	return func() { lockSettle = settle }
+}
+
+// storeLock will make the mock client keep the lock that was last written so that
+// reading it back returns it
+func storeLock(client *mocks.ShopifyClient, checksum string) {
+	var written shopify.Asset
+	client.On("UpdateAsset", mock.MatchedBy(isLock), checksum).Return(nil).Run(func(args mock.Arguments) {
+		written = args.Get(0).(shopify.Asset)
+	}).Once()
+	client.On("GetAsset", file.LockKey).Return(func(string) shopify.Asset { return written }, nil)
+}
+
+func TestAcquireLock(t *testing.T) {
+	defer withoutLockSettle()()
+	defer withoutCIDeployer()()
+	ctx, client, _, _, _ := createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme).Once()
+	storeLock(client, "")
+	held, err := acquireLock(ctx, "deploy", time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, "deploy", held.lock.Command)
+	assert.NotEqual(t, "", held.lock.Token)
+	assert.True(t, held.lock.ExpiresAt.After(time.Now()))
+
+	// the lock is public so it does not say who holds it
+	asset, _ := ctx.Client.GetAsset(file.LockKey)
+	var stored map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(asset.Value), &stored))
+	assert.Equal(t, []string{"command", "expires_at", "holder", "token"}, sortedKeys(stored))
+	assert.Equal(t, lockHolder(), stored["holder"])
+
+	client.On("DeleteAsset", shopify.Asset{Key: file.LockKey}).Return(nil).Once()
+	held.release()
+	client.AssertExpectations(t)
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Deployer = "release team"
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme).Once()
+	storeLock(client, "")
+	held, err = acquireLock(ctx, "deploy", time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, "release team", held.lock.Owner)
+	client.On("DeleteAsset", shopify.Asset{Key: file.LockKey}).Return(nil).Once()
+	held.release()
+}
+
+func TestAcquireLockRace(t *testing.T) {
+	defer withoutLockSettle()()
+	other := lockAsset(t, themeLock{Token: "other", Holder: "abc", Command: "deploy", ExpiresAt: time.Now().Add(time.Minute)})
+
+	ctx, client, _, _, _ := createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme).Once()
+	client.On("UpdateAsset", mock.MatchedBy(isLock), "").Return(nil).Once()
+	client.On("GetAsset", file.LockKey).Return(other, nil).Once()
+	_, err := acquireLock(ctx, "deploy", time.Hour)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "theme is locked by another process running deploy")
+	}
+	client.AssertNotCalled(t, "DeleteAsset", mock.Anything)
+
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme).Twice()
+	client.On("UpdateAsset", mock.MatchedBy(isLock), "").Return(nil).Once()
+	_, err = acquireLock(ctx, "deploy", time.Hour)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "the lock was removed")
+	}
+
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme).Once()
+	client.On("UpdateAsset", mock.MatchedBy(isLock), "").Return(nil).Once()
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, fmt.Errorf("server error")).Once()
+	_, err = acquireLock(ctx, "deploy", time.Hour)
+	assert.NotNil(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestAcquireLockHeld(t *testing.T) {
+	other := themeLock{Token: "other", Holder: "abc", Command: "deploy", ExpiresAt: time.Now().Add(time.Minute)}
+	otherAsset := lockAsset(t, other)
+
+	ctx, client, _, _, _ := createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(otherAsset, nil)
+	_, err := acquireLock(ctx, "watch", time.Hour)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "theme is locked by another process running deploy")
+		assert.Contains(t, err.Error(), "--steal-lock")
+	}
+	client.AssertNotCalled(t, "UpdateAsset", mock.Anything, mock.Anything)
+
+	mine := other
+	mine.Holder = lockHolder()
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(lockAsset(t, mine), nil)
+	_, err = acquireLock(ctx, "watch", time.Hour)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "another process on this computer")
+	}
+
+	named := other
+	named.Owner = "octocat"
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(lockAsset(t, named), nil)
+	_, err = acquireLock(ctx, "watch", time.Hour)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "theme is locked by octocat running deploy")
+	}
+
+	ctx, client, _, _, _ = createTestCtx()
+	ctx.Flags.StealLock = true
+	client.On("GetAsset", file.LockKey).Return(otherAsset, nil)
+	client.On("UpdateAsset", mock.MatchedBy(isLock), otherAsset.Checksum).Return(nil).Once()
+	held, err := acquireLock(ctx, "watch", time.Hour)
+	assert.Nil(t, err)
+	// the lock was taken by someone else before it was released, so it is left alone
+	held.release()
+	client.AssertNotCalled(t, "DeleteAsset", mock.Anything)
+
+	other.ExpiresAt = time.Now().Add(-time.Minute)
+	expiredAsset := lockAsset(t, other)
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(expiredAsset, nil)
+	client.On("UpdateAsset", mock.MatchedBy(isLock), expiredAsset.Checksum).Return(nil).Once()
+	_, err = acquireLock(ctx, "deploy", time.Hour)
+	assert.Nil(t, err)
+	client.AssertExpectations(t)
+
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, fmt.Errorf("server error"))
+	_, err = acquireLock(ctx, "deploy", time.Hour)
+	assert.NotNil(t, err)
+
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme)
+	client.On("UpdateAsset", mock.MatchedBy(isLock), "").Return(fmt.Errorf("checksum mismatch"))
+	_, err = acquireLock(ctx, "deploy", time.Hour)
+	assert.NotNil(t, err)
+}
+
+func TestLockRefresh(t *testing.T) {
+	defer withoutLockSettle()()
+	ctx, client, _, _, _ := createTestCtx()
+	refreshed := make(chan string, 10)
+	var written atomic.Value
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme).Once()
+	client.On("UpdateAsset", mock.MatchedBy(isLock), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written.Store(args.Get(0).(shopify.Asset))
+		select {
+		case refreshed <- args.String(1):
+		default:
+		}
+	})
+	client.On("GetAsset", file.LockKey).Return(func(string) shopify.Asset { return written.Load().(shopify.Asset) }, nil).Once()
+	held, err := acquireLock(ctx, "watch", time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, "", <-refreshed)
+	// every refresh only replaces the lock this process wrote
+	assert.NotEqual(t, "", <-refreshed)
+
+	client.On("GetAsset", file.LockKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme)
+	held.release()
+}
+
+func TestWithLock(t *testing.T) {
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.ReadOnly = true
+	ran := false
+	err := withLock("deploy", func(*cmdutil.Ctx) error {
+		ran = true
+		return nil
+	})(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ran)
+	client.AssertNotCalled(t, "GetAsset", mock.Anything)
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
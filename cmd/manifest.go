@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/git"
+	"github.com/Shopify/themekit/src/release"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+// ciDeployerVars are the environment variables that CI services set to the
+// account that started a build, which is already known to everyone on the project
+var ciDeployerVars = []string{"GITHUB_ACTOR", "GITLAB_USER_LOGIN", "CIRCLE_USERNAME", "BUILDKITE_BUILD_CREATOR"}
+
+// deployManifest is written to the theme after a successful deploy so that it
+// can be found out what is deployed to a theme. Theme files are served publicly
+// so it does not have the user or host that deployed.
+type deployManifest struct {
+	ThemeKitVersion string    `json:"themekit_version"`
+	GitCommit       string    `json:"git_commit,omitempty"`
+	DeployedBy      string    `json:"deployed_by"`
+	DeployedAt      time.Time `json:"deployed_at"`
+	Updated         []string  `json:"updated"`
+	Removed         []string  `json:"removed"`
+}
+
+// writeManifest will record the deploy on the theme
+func writeManifest(ctx *cmdutil.Ctx, updated, removed []string) error {
+	manifest := deployManifest{
+		ThemeKitVersion: release.ThemeKitVersion.String(),
+		DeployedBy:      deployer(ctx.Env),
+		DeployedAt:      time.Now().UTC(),
+		Updated:         append([]string{}, updated...),
+		Removed:         append([]string{}, removed...),
+	}
+	// the project does not have to be in a git repository
+	manifest.GitCommit, _ = git.Commit(ctx.Env.Directory)
+	sort.Strings(manifest.Updated)
+	sort.Strings(manifest.Removed)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ctx.Client.UpdateAsset(shopify.NewAsset(file.ManifestKey(ctx.Env), data), "")
+}
+
+// readManifest will read the manifest of the last deploy to the theme. If no
+// deploy was recorded then false is returned.
+func readManifest(ctx *cmdutil.Ctx) (deployManifest, bool, error) {
+	var manifest deployManifest
+	asset, err := ctx.Client.GetAsset(file.ManifestKey(ctx.Env))
+	if err == shopify.ErrNotPartOfTheme {
+		return manifest, false, nil
+	} else if err != nil {
+		return manifest, false, err
+	}
+	return manifest, true, json.Unmarshal([]byte(asset.Value), &manifest)
+}
+
+// deployer will return a name for whoever is running themekit that is safe to
+// publish on the theme, or otherwise an id that does not reveal the user and host
+// but is the same every time they deploy.
+func deployer(e *env.Env) string {
+	if name := deployerName(e); name != "" {
+		return name
+	}
+	return "anonymous " + lockHolder()
+}
+
+// deployerName will return the deployer from the config, or the account that
+// started a CI build, and is empty if neither is known
+func deployerName(e *env.Env) string {
+	if e.Deployer != "" {
+		return e.Deployer
+	}
+	for _, name := range ciDeployerVars {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
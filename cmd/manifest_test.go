@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Shopify/themekit/src/cmdutil/_mocks"
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+func isManifest(asset shopify.Asset) bool {
+	return asset.Key == file.DefaultManifestKey
+}
+
+func expectManifest(client *mocks.ShopifyClient) {
+	client.On("UpdateAsset", mock.MatchedBy(isManifest), "").Return(nil).Once()
+}
+
+func TestWriteManifest(t *testing.T) {
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.ManifestKey = "assets/deploys.json"
+	var written deployManifest
+	client.On("UpdateAsset", mock.MatchedBy(func(asset shopify.Asset) bool {
+		return asset.Key == "assets/deploys.json" && json.Unmarshal([]byte(asset.Value), &written) == nil
+	}), "").Return(nil)
+	assert.Nil(t, writeManifest(ctx, []string{"b.liquid", "a.liquid"}, []string{"c.liquid"}))
+	assert.Equal(t, []string{"a.liquid", "b.liquid"}, written.Updated)
+	assert.Equal(t, []string{"c.liquid"}, written.Removed)
+	assert.NotEqual(t, "", written.ThemeKitVersion)
+	assert.False(t, written.DeployedAt.IsZero())
+	assert.Equal(t, deployer(ctx.Env), written.DeployedBy)
+}
+
+// withoutCIDeployer will unset the CI deployer variables until the returned
+// function is called
+func withoutCIDeployer() func() {
+	previous := map[string]string{}
+	for _, name := range ciDeployerVars {
+		previous[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+	return func() {
+		for name, value := range previous {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func TestDeployer(t *testing.T) {
+	defer withoutCIDeployer()()
+
+	anonymous := deployer(&env.Env{})
+	assert.Equal(t, "anonymous "+lockHolder(), anonymous)
+	host, _ := os.Hostname()
+	assert.NotContains(t, anonymous, host)
+
+	os.Setenv("GITHUB_ACTOR", "octocat")
+	assert.Equal(t, "octocat", deployer(&env.Env{}))
+	assert.Equal(t, "release team", deployer(&env.Env{Deployer: "release team"}))
+	assert.Equal(t, "octocat", deployerName(&env.Env{}))
+	os.Unsetenv("GITHUB_ACTOR")
+	assert.Equal(t, "", deployerName(&env.Env{}))
+}
+
+func TestInfo(t *testing.T) {
+	manifest := deployManifest{GitCommit: "abc123", DeployedBy: "someone", Updated: []string{"assets/app.js"}, Removed: []string{}}
+	data, _ := json.Marshal(manifest)
+
+	ctx, client, _, stdOut, _ := createTestCtx()
+	ctx.Flags.Verbose = true
+	client.On("GetAsset", file.DefaultManifestKey).Return(shopify.Asset{Key: file.DefaultManifestKey, Value: string(data)}, nil)
+	assert.Nil(t, info(ctx))
+	assert.Contains(t, stdOut.String(), "by:       someone")
+	assert.Contains(t, stdOut.String(), "abc123")
+	assert.Contains(t, stdOut.String(), "Updated assets/app.js")
+
+	ctx, client, _, stdOut, _ = createTestCtx()
+	client.On("GetAsset", file.DefaultManifestKey).Return(shopify.Asset{}, shopify.ErrNotPartOfTheme)
+	assert.Nil(t, info(ctx))
+	assert.Contains(t, stdOut.String(), "no deploy has been recorded")
+
+	ctx, client, _, _, _ = createTestCtx()
+	client.On("GetAsset", file.DefaultManifestKey).Return(shopify.Asset{}, fmt.Errorf("server error"))
+	assert.NotNil(t, info(ctx))
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/jsonmerge"
+	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
+)
+
+// conflictReport is written for a json asset that could not be merged
+type conflictReport struct {
+	Key       string               `json:"key"`
+	Conflicts []jsonmerge.Conflict `json:"conflicts"`
+}
+
+// recordSync will remember the asset as it was synced so that changes made on
+// shopify after this can be detected, and merged for json templates and locales.
+func recordSync(ctx *cmdutil.Ctx, asset shopify.Asset) {
+	if asset.Checksum == "" && asset.Value != "" {
+		asset.Checksum = shopify.NewAsset(asset.Key, []byte(asset.Value)).Checksum
+	}
+	ctx.State.Synced(asset.Key, asset.Checksum)
+	if jsonmerge.Mergeable(asset.Key) && asset.Value != "" {
+		if err := ctx.State.SetBase(asset.Key, []byte(asset.Value)); err != nil {
+			ctx.ErrLog.Printf("[%s] could not keep the synced version of %s: %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key), err)
+		}
+	}
+}
+
+// recordLocalSync will remember a local file that is the same as on shopify. The
+// file is only read if it is a json template or locale that has not had its
+// synced version kept yet.
+func recordLocalSync(ctx *cmdutil.Ctx, key, checksum string) {
+	if lastChecksum, synced := ctx.State.Checksum(key); synced && lastChecksum == checksum {
+		return
+	} else if !jsonmerge.Mergeable(key) {
+		ctx.State.Synced(key, checksum)
+		return
+	}
+
+	asset, err := shopify.ReadAsset(ctx.Env, key)
+	if err != nil {
+		ctx.State.Synced(key, checksum)
+		return
+	}
+	recordSync(ctx, asset)
+}
+
+// canMerge will return true if a conflicting asset can be three-way merged
+func canMerge(ctx *cmdutil.Ctx, path string) bool {
+	if !jsonmerge.Mergeable(path) {
+		return false
+	}
+	_, ok := ctx.State.Base(path)
+	return ok
+}
+
+// mergeAsset will merge a json asset that has changed both locally and on shopify
+// since it was last synced. The merged version is written to disk and, if upload
+// is true, uploaded to shopify. If the same values were changed on both sides then
+// nothing is changed, the conflicts are written to a report and the asset is
+// reported as a conflict. The file operation that was carried out is returned.
+func mergeAsset(ctx *cmdutil.Ctx, path string, upload bool) (file.Op, error) {
+	base, ok := ctx.State.Base(path)
+	if !ok {
+		ctx.Conflict(path)
+		return file.Conflict, nil
+	}
+
+	local, err := shopify.ReadAsset(ctx.Env, path)
+	if err != nil {
+		ctx.Err("[%s] error loading %s: %s", colors.Green(ctx.Env.Name), colors.Green(path), colors.Red(err))
+		return file.Merge, err
+	}
+
+	remote, err := ctx.Client.GetAsset(path)
+	if err != nil {
+		ctx.Err("[%s] error downloading %s: %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+		return file.Merge, err
+	}
+
+	data, conflicts, err := jsonmerge.ThreeWay(base, []byte(local.Value), []byte(remote.Value))
+	if err != nil {
+		ctx.Err("[%s] error merging %s: %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+		return file.Merge, err
+	} else if len(conflicts) > 0 {
+		report, err := writeConflictReport(ctx, path, conflicts)
+		if err != nil {
+			ctx.Err("[%s] error writing conflicts for %s: %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+			return file.Conflict, err
+		}
+		ctx.Conflict(fmt.Sprintf("%s (conflicts written to %s)", path, report))
+		return file.Conflict, nil
+	}
+
+	merged := shopify.NewAsset(path, data)
+	synced := remote
+	if upload {
+		if err := ctx.Client.UpdateAsset(merged, remote.Checksum); err != nil {
+			ctx.Err("[%s] (%s) %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+			return file.Merge, err
+		}
+		synced = merged
+	}
+
+	if err := merged.Write(ctx.Env.Directory); err != nil {
+		ctx.Err("[%s] error writing %s: %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+		return file.Merge, err
+	}
+	recordSync(ctx, synced)
+
+	if ctx.Flags.Verbose {
+		ctx.Log.Printf("[%s] Merged %s with the changes on shopify", colors.Green(ctx.Env.Name), colors.Blue(path))
+	}
+	return file.Merge, nil
+}
+
+func writeConflictReport(ctx *cmdutil.Ctx, path string, conflicts []jsonmerge.Conflict) (string, error) {
+	report := filepath.Join(state.Dir(ctx.Env), "conflicts", filepath.FromSlash(path))
+	data, err := json.MarshalIndent(conflictReport{Key: path, Conflicts: conflicts}, "", "  ")
+	if err != nil {
+		return report, err
+	}
+	if err := os.MkdirAll(filepath.Dir(report), 0755); err != nil {
+		return report, err
+	}
+	return report, ioutil.WriteFile(report, data, 0644)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/cmdutil/_mocks"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
+)
+
+const indexKey = "templates/index.json"
+
+func createMergeTestCtx(t *testing.T, base, local string) (*cmdutil.Ctx, *mocks.ShopifyClient, string) {
+	dir, err := ioutil.TempDir("", "themekit-merge")
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "templates"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, indexKey), []byte(local), 0644))
+
+	ctx, client, _, _, _ := createTestCtx()
+	ctx.Env.Name = "development"
+	ctx.Env.Directory = dir
+	ctx.State, err = state.Load(ctx.Env)
+	assert.Nil(t, err)
+	recordSync(ctx, shopify.NewAsset(indexKey, []byte(base)))
+	return ctx, client, dir
+}
+
+func TestMergeAsset(t *testing.T) {
+	ctx, client, dir := createMergeTestCtx(t, `{"a": 1, "b": 1}`, `{"a": 2, "b": 1}`)
+	defer os.RemoveAll(dir)
+	remote := shopify.Asset{Key: indexKey, Value: `{"a": 1, "b": 2}`, Checksum: "remote"}
+	client.On("GetAsset", indexKey).Return(remote, nil)
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key == indexKey }), "remote").Return(nil)
+	assert.True(t, canMerge(ctx, indexKey))
+	mergeAsset(ctx, indexKey, true)
+
+	merged, _ := shopify.ReadAsset(ctx.Env, indexKey)
+	assert.JSONEq(t, `{"a": 2, "b": 2}`, merged.Value)
+	checksum, _ := ctx.State.Checksum(indexKey)
+	assert.Equal(t, merged.Checksum, checksum)
+	base, ok := ctx.State.Base(indexKey)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"a": 2, "b": 2}`, string(base))
+}
+
+func TestMergeAssetWithConflicts(t *testing.T) {
+	ctx, client, dir := createMergeTestCtx(t, `{"a": 1}`, `{"a": 2}`)
+	defer os.RemoveAll(dir)
+	client.On("GetAsset", indexKey).Return(shopify.Asset{Key: indexKey, Value: `{"a": 3}`}, nil)
+	mergeAsset(ctx, indexKey, false)
+
+	local, _ := shopify.ReadAsset(ctx.Env, indexKey)
+	assert.Equal(t, `{"a": 2}`, local.Value)
+	report, err := ioutil.ReadFile(filepath.Join(state.Dir(ctx.Env), "conflicts", "templates", "index.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(report), `"path": "a"`)
+	assert.Contains(t, string(report), `"remote": 3`)
+}
+
+func TestConflictActionsMerge(t *testing.T) {
+	ctx, _, dir := createMergeTestCtx(t, `{"a": 1}`, `{"a": 2}`)
+	defer os.RemoveAll(dir)
+
+	assert.Equal(t, file.Merge, deployConflictAction(ctx, indexKey))
+	assert.Equal(t, file.Merge, downloadConflictAction(ctx, indexKey))
+	assert.Equal(t, file.Conflict, deployConflictAction(ctx, "templates/index.liquid"))
+
+	ctx.Flags.OnConflict = conflictOverwrite
+	assert.Equal(t, file.Get, downloadConflictAction(ctx, indexKey))
+}
+
+func TestRecordLocalSync(t *testing.T) {
+	ctx, _, dir := createMergeTestCtx(t, `{"a": 1}`, `{"a": 2}`)
+	defer os.RemoveAll(dir)
+
+	local := shopify.NewAsset(indexKey, []byte(`{"a": 2}`))
+	recordLocalSync(ctx, indexKey, local.Checksum)
+	checksum, _ := ctx.State.Checksum(indexKey)
+	assert.Equal(t, local.Checksum, checksum)
+	base, ok := ctx.State.Base(indexKey)
+	assert.True(t, ok)
+	assert.Equal(t, `{"a": 2}`, string(base))
+
+	recordLocalSync(ctx, "assets/image.png", "imagechecksum")
+	checksum, _ = ctx.State.Checksum("assets/image.png")
+	assert.Equal(t, "imagechecksum", checksum)
+	_, ok = ctx.State.Base("assets/image.png")
+	assert.False(t, ok)
+}
@@ -2,13 +2,21 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	_ "github.com/Shopify/themekit/cmd/static" // This will import the asset bundle
+	"github.com/Shopify/themekit/src/archive"
 	"github.com/Shopify/themekit/src/cmdutil"
 	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
 	"github.com/Shopify/themekit/src/static"
 )
 
@@ -21,18 +29,70 @@ var newCmd = &cobra.Command{
 	the same directory it's called from. Use the --dir flag to specify a custom directory where the generated files
 	should be placed.
 
+	Pass --from with a theme zip, like one bought from the theme store, or a theme directory to
+	create the theme from its files instead of the blank template.
+
+	Pass --template with a starter template directory or archive, or the name of a template
+	kept in the themekit/templates directory of your user config directory, to create the theme
+	from your own starter theme. The placeholders [[ .Name ]], [[ .Store ]] and [[ .Locale ]] are
+	filled in with the theme name, store and --locale in the names and contents of its files.
+	These are the only placeholders. Templates are not go templates, so no other template
+	syntax like conditions, loops or functions works, and anything else in the files,
+	including other text in [[ ]], is left as it is.
+
+	Files that already exist in the project directory are kept unless --on-exists is passed.
+	Pass --on-exists=overwrite to replace them, --on-exists=backup to move them to
+	.themekit/backup first or --on-exists=prompt to be asked about each of them. Only the
+	files of the new theme are uploaded, the same way as deploy, and nothing else in the
+	project directory is, except layout/theme.liquid when it already existed and was kept
+	because shopify will not accept a theme without it. Nothing is created on Shopify
+	and the config is not changed if the theme files cannot be put in place.
+
   For more information, refer to https://shopify.dev/tools/theme-kit/command-reference#new.
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// This is a hack to get around theme ID validation for the list operation which doesn't need it
+		if cmd.Flags().Changed("from") && flags.From == "" {
+			return shopify.ErrZipPathRequired
+		} else if flags.From != "" && flags.Template != "" {
+			return fmt.Errorf("--from and --template cannot be passed together")
+		}
+		switch flags.OnExists {
+		case "", static.ExistsSkip, static.ExistsOverwrite, static.ExistsBackup, static.ExistsPrompt:
+		default:
+			return fmt.Errorf("invalid --on-exists value %q, expected one of %s, %s, %s or %s", flags.OnExists, static.ExistsSkip, static.ExistsOverwrite, static.ExistsBackup, static.ExistsPrompt)
+		}
+		// The theme does not exist until it is created so a placeholder theme id is
+		// used to get past the validation of the environment. The theme id, and the
+		// sync state that depends on it, are replaced once the theme is created.
 		flags.ThemeID = "1337"
 		return cmdutil.ForDefaultClient(flags, args, func(ctx *cmdutil.Ctx) error {
+			if ctx.Flags.From != "" {
+				return newTheme(ctx, copyTheme(ctx.Flags.From))
+			} else if ctx.Flags.Template != "" {
+				return newTheme(ctx, templateTheme(ctx.Flags.Template))
+			}
 			return newTheme(ctx, static.Unbundle)
 		})
 	},
 }
 
-func newTheme(ctx *cmdutil.Ctx, generate func(ctx *cmdutil.Ctx) error) error {
+// themeLayoutKey is the file that shopify will not accept a theme without
+const themeLayoutKey = "layout/theme.liquid"
+
+// newTheme will put the files of the theme in the project directory with generate,
+// then create the theme on shopify and upload them. The files are put in place
+// first so that nothing is created, and the config is not changed, if they cannot
+// make up a theme.
+func newTheme(ctx *cmdutil.Ctx, generate func(ctx *cmdutil.Ctx) ([]string, error)) error {
+	keys, err := generate(ctx)
+	if err != nil {
+		return err
+	} else if len(keys) == 0 {
+		return fmt.Errorf("no theme files were generated, files that already exist are kept unless --on-exists is passed")
+	} else if keys, err = withThemeLayout(ctx, keys); err != nil {
+		return err
+	}
+
 	theme, err := ctx.Client.CreateNewTheme(ctx.Flags.Name)
 	if err != nil {
 		if err == shopify.ErrThemeNameRequired {
@@ -43,15 +103,110 @@ func newTheme(ctx *cmdutil.Ctx, generate func(ctx *cmdutil.Ctx) error) error {
 	ctx.Log.Printf("[%s] theme created", colors.Yellow(ctx.Env.Domain))
 
 	ctx.Env.ThemeID = fmt.Sprintf("%v", theme.ID)
+	if ctx.State, err = state.Load(ctx.Env); err != nil {
+		ctx.ErrLog.Printf("[%s] could not read the last sync state, changes made on shopify will not be detected: %s", colors.Yellow(ctx.Env.Name), err)
+	}
 	if err := createConfig(ctx); err != nil {
 		return err
 	}
 	ctx.Log.Printf("[%s] config created", colors.Yellow(ctx.Env.Domain))
 
-	if err := generate(ctx); err != nil {
-		return err
-	}
-
 	ctx.Log.Printf("[%s] uploading new files to shopify", colors.Yellow(ctx.Env.Domain))
+	// only the generated files are deployed so that nothing else in the project
+	// directory is uploaded and nothing on the new theme is removed
+	ctx.Args = keys
 	return deploy(ctx)
 }
+
+// withThemeLayout will add the theme layout to the keys if it was not generated
+// because it already existed and was kept, since the new theme cannot be created
+// without it. It fails if there is no theme layout at all.
+func withThemeLayout(ctx *cmdutil.Ctx, keys []string) ([]string, error) {
+	for _, key := range keys {
+		if key == themeLayoutKey {
+			return keys, nil
+		}
+	}
+	if _, err := os.Stat(filepath.Join(ctx.Env.Directory, filepath.FromSlash(themeLayoutKey))); err != nil {
+		return nil, fmt.Errorf("the new theme does not have %s, shopify will not accept a theme without it", themeLayoutKey)
+	}
+	ctx.Log.Printf("[%s] %s already exists and is uploaded as it is", colors.Yellow(ctx.Env.Domain), themeLayoutKey)
+	return append(keys, themeLayoutKey), nil
+}
+
+// copyTheme will return a generator that puts the theme files from an archive or
+// a directory in the project directory, without overwriting files that exist
+func copyTheme(source string) func(ctx *cmdutil.Ctx) ([]string, error) {
+	return func(ctx *cmdutil.Ctx) ([]string, error) {
+		return copyThemeFiles(ctx, source, nil)
+	}
+}
+
+// copyThemeFiles will put the theme files from an archive or a directory in the
+// project directory and return their keys. If render is not nil then the name and
+// contents of every file are passed through it first.
+func copyThemeFiles(ctx *cmdutil.Ctx, source string, render func(name string, contents io.Reader) (string, io.Reader, error)) ([]string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", source, err)
+	}
+
+	w := static.NewWriter(ctx, ctx.Env.Directory)
+	found := 0
+	copyFile := func(name string, contents io.Reader) error {
+		if render != nil {
+			var err error
+			if name, contents, err = render(name, contents); err != nil {
+				return err
+			}
+		}
+		key := themeSourceKey(name)
+		if key == "" {
+			return nil
+		}
+		found++
+		return w.Write(key, contents)
+	}
+
+	if !info.IsDir() {
+		err = archive.Each(source, copyFile)
+	} else {
+		err = filepath.Walk(source, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			name, err := filepath.Rel(source, path)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return copyFile(filepath.ToSlash(name), f)
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not copy the theme from %s: %s", source, err)
+	} else if found == 0 {
+		return nil, fmt.Errorf("%s does not contain any theme files", source)
+	}
+	w.Report()
+	return w.Keys(), nil
+}
+
+// themeSourceKey will return the key of a file in a theme archive or directory, or
+// an empty string if it is not a theme file. Themes are often zipped inside of a
+// directory named after the theme, so that directory is left out.
+func themeSourceKey(name string) string {
+	base := path.Base(name)
+	if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, "._") || base == ".DS_Store" {
+		return ""
+	} else if file.IsAssetKey(name) {
+		return name
+	} else if parts := strings.SplitN(name, "/", 2); len(parts) == 2 && file.IsAssetKey(parts[1]) {
+		return parts[1]
+	}
+	return ""
+}
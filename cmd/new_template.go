@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+)
+
+// placeholderPattern matches the placeholders of a starter template, like
+// [[ .Name ]]. Only these are replaced so that anything else in the files, like
+// liquid or nested arrays in json and javascript, is left alone.
+var placeholderPattern = regexp.MustCompile(`\[\[\s*\.(Name|Store|Locale)\s*\]\]`)
+
+// templateExts are the archive formats that a named template can be kept in
+var templateExts = []string{".zip", ".tar.gz", ".tgz"}
+
+// templatesDir will return the directory in the user config directory that named
+// starter templates are kept in
+var templatesDir = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "themekit", "templates"), nil
+}
+
+// templateData is what can be filled into the placeholders of a starter template
+type templateData struct {
+	Name   string
+	Store  string
+	Locale string
+}
+
+// templateTheme will return a generator that puts the files from a starter
+// template in the project directory, filling in the placeholders in their names and
+// contents. The template can be a directory, an archive or the name of a template
+// in the templates directory.
+func templateTheme(name string) func(ctx *cmdutil.Ctx) ([]string, error) {
+	return func(ctx *cmdutil.Ctx) ([]string, error) {
+		source, err := findTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		data := templateData{Name: ctx.Flags.Name, Store: ctx.Env.Domain, Locale: ctx.Flags.Locale}
+		return copyThemeFiles(ctx, source, func(name string, contents io.Reader) (string, io.Reader, error) {
+			return renderTemplateFile(name, contents, data)
+		})
+	}
+}
+
+// findTemplate will return the path to the template if it exists, or otherwise the
+// path to the template with that name in the templates directory
+func findTemplate(name string) (string, error) {
+	if _, err := os.Stat(name); err == nil {
+		return name, nil
+	}
+	dir, err := templatesDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find the template %s: %s", name, err)
+	}
+	for _, ext := range append([]string{""}, templateExts...) {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("could not find the template %s, it is not a directory or archive and is not in %s", name, dir)
+}
+
+// renderTemplateFile will fill the placeholders in the name and contents of a
+// template file. Files that are not text are left as they are.
+func renderTemplateFile(name string, contents io.Reader, data templateData) (string, io.Reader, error) {
+	raw, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return "", nil, err
+	}
+	renderedName := string(renderTemplate([]byte(name), data))
+	if !utf8.Valid(raw) {
+		return renderedName, bytes.NewReader(raw), nil
+	}
+	return renderedName, bytes.NewReader(renderTemplate(raw, data)), nil
+}
+
+func renderTemplate(text []byte, data templateData) []byte {
+	values := map[string]string{"Name": data.Name, "Store": data.Store, "Locale": data.Locale}
+	return placeholderPattern.ReplaceAllFunc(text, func(placeholder []byte) []byte {
+		return []byte(values[string(placeholderPattern.FindSubmatch(placeholder)[1])])
+	})
+}
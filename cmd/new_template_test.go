@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateTheme(t *testing.T) {
+	templates := createThemeDir(t, map[string]string{
+		"agency/layout/theme.liquid":                `{{ content_for_layout }} [[ .Store ]]`,
+		"agency/locales/[[ .Locale ]].default.json": `{"name": "[[ .Name ]]"}`,
+		"agency/assets/logo.png":                    "\x89PNG\x00[[",
+		"agency/assets/vendor.js":                   `var a=[[0]];b=a[[0]][0];c="[[.Name]]";d="[[ .Nope ]]"`,
+		"agency/templates/index.json":               `{"grid": [[1, 2], [3]], "title": "[[.Name]]"}`,
+	})
+	defer os.RemoveAll(templates)
+	defer func(original func() (string, error)) { templatesDir = original }(templatesDir)
+	templatesDir = func() (string, error) { return templates, nil }
+
+	for _, name := range []string{"agency", filepath.Join(templates, "agency")} {
+		dir := createThemeDir(t, map[string]string{})
+		ctx, _, _, _, _ := createTestCtx()
+		ctx.Env.Directory = dir
+		ctx.Env.Domain = "shop.myshopify.com"
+		ctx.Flags.Name = "Spring"
+		ctx.Flags.Locale = "fr"
+		keys, err := templateTheme(name)(ctx)
+		assert.Nil(t, err, name)
+		assert.Equal(t, []string{"assets/logo.png", "assets/vendor.js", "layout/theme.liquid", "locales/fr.default.json", "templates/index.json"}, keys)
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "layout", "theme.liquid"))
+		assert.Nil(t, err)
+		assert.Equal(t, "{{ content_for_layout }} shop.myshopify.com", string(contents))
+		contents, err = ioutil.ReadFile(filepath.Join(dir, "locales", "fr.default.json"))
+		assert.Nil(t, err)
+		assert.Equal(t, `{"name": "Spring"}`, string(contents))
+		contents, err = ioutil.ReadFile(filepath.Join(dir, "assets", "logo.png"))
+		assert.Nil(t, err)
+		assert.Equal(t, "\x89PNG\x00[[", string(contents))
+		// only the placeholders are filled, anything else in brackets is kept
+		contents, err = ioutil.ReadFile(filepath.Join(dir, "assets", "vendor.js"))
+		assert.Nil(t, err)
+		assert.Equal(t, `var a=[[0]];b=a[[0]][0];c="Spring";d="[[ .Nope ]]"`, string(contents))
+		contents, err = ioutil.ReadFile(filepath.Join(dir, "templates", "index.json"))
+		assert.Nil(t, err)
+		assert.Equal(t, `{"grid": [[1, 2], [3]], "title": "Spring"}`, string(contents))
+		os.RemoveAll(dir)
+	}
+
+	ctx, _, _, _, _ := createTestCtx()
+	_, err := templateTheme("nope")(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "could not find the template nope")
+	}
+}
@@ -3,51 +3,178 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
+	"github.com/Shopify/themekit/src/archive"
 	"github.com/Shopify/themekit/src/cmdutil"
 	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
 )
 
 func TestNewTheme(t *testing.T) {
 	name := "name"
+	dir := createThemeDir(t, map[string]string{"layout/theme.liquid": `layout`})
+	defer os.RemoveAll(dir)
+	generated := func(ctx *cmdutil.Ctx) ([]string, error) { return []string{"layout/theme.liquid"}, nil }
 
-	ctx, client, conf, _, _ := createTestCtx()
+	ctx, client, _, _, _ := createTestCtx()
 	ctx.Flags.Name = name
-	client.On("CreateNewTheme", name).Return(shopify.Theme{ID: 42}, nil)
-	conf.On("Set", "development", env.Env{ThemeID: "42"}).Return(nil, nil)
-	conf.On("Save").Return(nil)
-	client.On("GetAllAssets").Return([]shopify.Asset{}, nil)
-	err := newTheme(ctx, func(ctx *cmdutil.Ctx) error { return nil })
+	err := newTheme(ctx, func(ctx *cmdutil.Ctx) ([]string, error) { return nil, nil })
 	assert.Error(t, err)
+	client.AssertNotCalled(t, "CreateNewTheme", mock.Anything)
 
 	ctx, client, _, _, _ = createTestCtx()
+	ctx.Env.Directory = dir
 	ctx.Flags.Name = name
 	client.On("CreateNewTheme", name).Return(shopify.Theme{}, fmt.Errorf("can't create theme"))
-	err = newTheme(ctx, func(ctx *cmdutil.Ctx) error { return nil })
+	err = newTheme(ctx, generated)
 	if assert.NotNil(t, err) {
 		assert.Contains(t, err.Error(), "can't create theme")
 	}
 
-	ctx, client, conf, _, _ = createTestCtx()
+	ctx, client, conf, _, _ := createTestCtx()
+	ctx.Env.Directory = dir
 	ctx.Flags.Name = name
 	client.On("CreateNewTheme", name).Return(shopify.Theme{ID: 44}, nil)
-	conf.On("Set", "development", env.Env{ThemeID: "44"}).Return(nil, fmt.Errorf("cant set config"))
-	err = newTheme(ctx, func(ctx *cmdutil.Ctx) error { return nil })
+	conf.On("Set", "development", env.Env{Directory: dir, ThemeID: "44"}).Return(nil, fmt.Errorf("cant set config"))
+	err = newTheme(ctx, generated)
 	if assert.NotNil(t, err) {
 		assert.Contains(t, err.Error(), "cant set config")
 	}
 
 	ctx, client, conf, _, _ = createTestCtx()
 	ctx.Flags.Name = name
-	client.On("CreateNewTheme", name).Return(shopify.Theme{ID: 48}, nil)
-	conf.On("Set", "development", env.Env{ThemeID: "48"}).Return(nil, nil)
-	conf.On("Save").Return(nil)
-	err = newTheme(ctx, func(ctx *cmdutil.Ctx) error { return errors.New("oh no") })
+	err = newTheme(ctx, func(ctx *cmdutil.Ctx) ([]string, error) { return nil, errors.New("oh no") })
 	if assert.NotNil(t, err) {
 		assert.Contains(t, err.Error(), "oh no")
 	}
+	client.AssertNotCalled(t, "CreateNewTheme", mock.Anything)
+	conf.AssertNotCalled(t, "Set", mock.Anything, mock.Anything)
+}
+
+func TestNewThemeLayout(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{"snippets/card.liquid": `card`})
+	defer os.RemoveAll(dir)
+
+	// the theme cannot be created without a layout so nothing is created
+	ctx, client, conf, _, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Flags.Name = "name"
+	err := newTheme(ctx, func(ctx *cmdutil.Ctx) ([]string, error) { return []string{"snippets/card.liquid"}, nil })
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "does not have layout/theme.liquid")
+	}
+	client.AssertNotCalled(t, "CreateNewTheme", mock.Anything)
+	conf.AssertNotCalled(t, "Set", mock.Anything, mock.Anything)
+
+	// a layout that already existed and was kept is uploaded with the new files
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "layout"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "layout", "theme.liquid"), []byte("mine"), 0644))
+	keys, err := withThemeLayout(ctx, []string{"snippets/card.liquid"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"snippets/card.liquid", "layout/theme.liquid"}, keys)
+
+	keys, err = withThemeLayout(ctx, []string{"layout/theme.liquid"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"layout/theme.liquid"}, keys)
+}
+
+func TestCopyTheme(t *testing.T) {
+	src := createThemeDir(t, map[string]string{
+		"dawn/layout/theme.liquid":        `layout`,
+		"dawn/snippets/card.liquid":       `card`,
+		"dawn/README.md":                  `readme`,
+		"__MACOSX/dawn/._layout":          `junk`,
+		"dawn/snippets/._card.liquid":     `junk`,
+		"dawn/templates/customers/a.json": `{}`,
+	})
+	defer os.RemoveAll(src)
+
+	zipPath := filepath.Join(src, "dawn.zip")
+	writer, err := archive.Create(zipPath)
+	assert.Nil(t, err)
+	assert.Nil(t, filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || path == zipPath {
+			return err
+		}
+		name, _ := filepath.Rel(src, path)
+		contents, _ := ioutil.ReadFile(path)
+		return writer.Add(filepath.ToSlash(name), contents)
+	}))
+	assert.Nil(t, writer.Close())
+
+	for _, source := range []string{zipPath, filepath.Join(src, "dawn")} {
+		dir := createThemeDir(t, map[string]string{"snippets/card.liquid": `mine`})
+		ctx, _, _, _, _ := createTestCtx()
+		ctx.Env.Directory = dir
+		keys, err := copyTheme(source)(ctx)
+		assert.Nil(t, err, source)
+		// the card snippet already existed and was kept so it is not one of the new files
+		assert.Equal(t, []string{"layout/theme.liquid", "templates/customers/a.json"}, keys, source)
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "layout", "theme.liquid"))
+		assert.Nil(t, err, source)
+		assert.Equal(t, "layout", string(contents))
+		contents, _ = ioutil.ReadFile(filepath.Join(dir, "snippets", "card.liquid"))
+		assert.Equal(t, "mine", string(contents), source)
+		_, err = os.Stat(filepath.Join(dir, "templates", "customers", "a.json"))
+		assert.Nil(t, err, source)
+		_, err = os.Stat(filepath.Join(dir, "README.md"))
+		assert.True(t, os.IsNotExist(err), source)
+		_, err = os.Stat(filepath.Join(dir, "snippets", "._card.liquid"))
+		assert.True(t, os.IsNotExist(err), source)
+		os.RemoveAll(dir)
+	}
+
+	ctx, _, _, _, _ := createTestCtx()
+	_, err = copyTheme(filepath.Join(src, "nope.zip"))(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "could not read")
+	}
+
+	_, err = copyTheme(filepath.Join(src, "__MACOSX"))(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "does not contain any theme files")
+	}
+}
+
+func TestNewThemeFrom(t *testing.T) {
+	src := createThemeDir(t, map[string]string{
+		"layout/theme.liquid":  `{% render 'card' %}`,
+		"snippets/card.liquid": `card`,
+	})
+	defer os.RemoveAll(src)
+	dir := createThemeDir(t, map[string]string{"snippets/other.liquid": `other`})
+	defer os.RemoveAll(dir)
+
+	ctx, client, conf, _, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Flags.Name = "bought"
+	client.On("CreateNewTheme", "bought").Return(shopify.Theme{ID: 42}, nil)
+	conf.On("Set", "development", mock.Anything).Return(nil, nil)
+	conf.On("Save").Return(nil)
+	client.On("GetAllAssets").Return([]shopify.Asset{{Key: "templates/index.liquid"}}, nil)
+	uploaded := []string{}
+	client.On("UpdateAsset", mock.MatchedBy(func(a shopify.Asset) bool { return a.Key != file.DefaultManifestKey }), "").
+		Run(func(args mock.Arguments) { uploaded = append(uploaded, args.Get(0).(shopify.Asset).Key) }).
+		Return(nil)
+	expectManifest(client)
+	assert.Nil(t, newTheme(ctx, copyTheme(src)))
+	assert.Equal(t, []string{"snippets/card.liquid", "layout/theme.liquid"}, uploaded)
+	client.AssertNotCalled(t, "DeleteAsset", mock.Anything)
+
+	// the uploads are recorded for the new theme so the next command knows them
+	assert.Nil(t, ctx.State.Save())
+	synced, err := state.Load(&env.Env{Directory: dir, ThemeID: "42"})
+	assert.Nil(t, err)
+	_, ok := synced.Checksum("snippets/card.liquid")
+	assert.True(t, ok)
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Shopify/themekit/src/archive"
+	"github.com/Shopify/themekit/src/check"
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package [filename.zip]",
+	Short: "Package the theme into a zip that can be uploaded to shopify",
+	Long: `Package will zip up all of the theme files in your project directory that
+ are not ignored, so that the theme can be uploaded in the shopify admin or to the
+ theme store. The zip is named after the project directory unless a file name is
+ passed.
+
+ The theme is checked before it is packaged and nothing is written if it is
+ missing layout/theme.liquid, its config files or an index template, or if it has
+ files that shopify would compile to the same name, like [filename].js and
+ [filename].js.liquid.
+
+ Package does not connect to shopify so your config does not need a password,
+ theme id or store.
+ `,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmdutil.ForLocalEnv(flags, args, packageTheme)
+	},
+}
+
+func packageTheme(ctx *cmdutil.Ctx) error {
+	filename := filepath.Base(ctx.Env.Directory) + ".zip"
+	if len(ctx.Args) > 0 {
+		filename = ctx.Args[0]
+	}
+	if !strings.HasSuffix(filename, ".zip") {
+		return fmt.Errorf("[%s] the package name must end in .zip", colors.Green(ctx.Env.Name))
+	}
+
+	assets, err := shopify.FindAssets(ctx.Env)
+	if err != nil {
+		return err
+	}
+	keys := []string{}
+	for _, asset := range assets {
+		if file.IsAssetKey(asset.Key) {
+			keys = append(keys, asset.Key)
+		} else if ctx.Flags.Verbose {
+			ctx.Log.Printf("[%s] Skipped %s because it is not in a theme directory", colors.Green(ctx.Env.Name), colors.Blue(asset.Key))
+		}
+	}
+	sort.Strings(keys)
+
+	if problems := check.Structure(keys); len(problems) > 0 {
+		messages := []string{}
+		for _, problem := range problems {
+			messages = append(messages, problem.String())
+		}
+		return fmt.Errorf("[%s] the theme cannot be packaged:\n\t%s", colors.Green(ctx.Env.Name), strings.Join(messages, "\n\t"))
+	}
+
+	writer, err := archive.Create(filename)
+	if err != nil {
+		return fmt.Errorf("[%s] could not create %s: %s", colors.Green(ctx.Env.Name), filename, err)
+	}
+	for _, key := range keys {
+		// the files are packaged exactly as they are on disk
+		contents, err := ioutil.ReadFile(filepath.Join(ctx.Env.Directory, filepath.FromSlash(key)))
+		if err == nil {
+			err = writer.Add(key, contents)
+		}
+		if err != nil {
+			writer.Close()
+			return fmt.Errorf("[%s] could not add %s to %s: %s", colors.Green(ctx.Env.Name), key, filename, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("[%s] could not write %s: %s", colors.Green(ctx.Env.Name), filename, err)
+	}
+
+	ctx.Log.Printf("[%s] Packaged %d files into %s", colors.Green(ctx.Env.Name), len(keys), colors.Blue(filename))
+	return nil
+}
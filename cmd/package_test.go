@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageTheme(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"layout/theme.liquid":         `{{ content_for_layout }}`,
+		"config/settings_schema.json": `[]`,
+		"config/settings_data.json":   `/* comment */ {"current": {}}`,
+		"templates/index.json":        `{}`,
+		"assets/app.js":               `var a;`,
+		"assets/ignored.js":           `var b;`,
+		"README.md":                   `readme`,
+	})
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "theme.zip")
+
+	ctx, _, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Env.IgnoredFiles = []string{"assets/ignored.js"}
+	ctx.Args = []string{out}
+	assert.Nil(t, packageTheme(ctx))
+	assert.Contains(t, stdOut.String(), "Packaged 5 files into")
+
+	r, err := zip.OpenReader(out)
+	assert.Nil(t, err)
+	defer r.Close()
+	names := []string{}
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"assets/app.js", "config/settings_data.json", "config/settings_schema.json", "layout/theme.liquid", "templates/index.json"}, names)
+
+	ctx.Args = []string{filepath.Join(dir, "theme.tar.gz")}
+	assert.NotNil(t, packageTheme(ctx))
+}
+
+func TestPackageThemeIncomplete(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"config/settings_schema.json": `[]`,
+		"assets/app.js":               `var a;`,
+		"assets/app.js.liquid":        `var a;`,
+	})
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "theme.zip")
+
+	ctx, _, _, _, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	ctx.Args = []string{out}
+	err := packageTheme(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "the theme cannot be packaged")
+		assert.Contains(t, err.Error(), "missing layout/theme.liquid")
+		assert.Contains(t, err.Error(), "missing config/settings_data.json")
+		assert.Contains(t, err.Error(), "missing templates/index.liquid or templates/index.json")
+		assert.NotContains(t, err.Error(), "settings_schema")
+		assert.Contains(t, err.Error(), "conflicts with")
+	}
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+}
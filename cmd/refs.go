@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Shopify/themekit/src/check"
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/liquid"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+var refsCmd = &cobra.Command{
+	Use:   "refs [files]",
+	Short: "Show how theme files reference each other",
+	Long: `Refs reads the liquid and json files in your project directory to find the
+ snippets, sections and assets that they reference with render, include, section
+ and sections tags, the asset_url and asset_img_url filters and the section types
+ of json templates.
+
+ With no files, refs will show references to files that do not exist and the
+ snippets, sections and assets that no template or layout uses, either directly
+ or through other files. With files, refs will show the templates and layouts
+ that depend on each of them.
+
+ Files that are referenced with a variable, like {% render block.type %}, or only
+ from javascript, or that are added to a template in the theme editor, cannot be
+ found so check unused files before deleting them.
+
+ Refs does not connect to shopify so your config does not need a password, theme
+ id or store.
+ `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmdutil.ForLocalEnv(flags, args, showRefs)
+	},
+}
+
+func showRefs(ctx *cmdutil.Ctx) error {
+	keys, err := shopify.AssetKeys(ctx.Env)
+	if err != nil {
+		return err
+	}
+	graph, err := liquid.NewGraph(keys, func(key string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(ctx.Env.Directory, filepath.FromSlash(key)))
+	})
+	if err != nil {
+		return fmt.Errorf("[%s] could not read the theme: %s", colors.Green(ctx.Env.Name), err)
+	}
+
+	if len(ctx.Args) > 0 {
+		return showDependents(ctx, keys, graph)
+	}
+
+	missing := graph.Missing()
+	for _, ref := range missing {
+		printProblem(ctx, check.Problem{Key: ref.From, Line: ref.Line, Message: fmt.Sprintf("%s does not exist", ref.To)})
+	}
+	unused := graph.Unused()
+	for _, key := range unused {
+		printProblem(ctx, check.Problem{Key: key, Message: "is not used by any template or layout"})
+	}
+	ctx.Log.Printf("[%s] %d missing references, %d unused files", colors.Green(ctx.Env.Name), len(missing), len(unused))
+	return nil
+}
+
+func showDependents(ctx *cmdutil.Ctx, keys []string, graph *liquid.Graph) error {
+	found := map[string]bool{}
+	for _, key := range keys {
+		found[key] = true
+	}
+	for _, key := range ctx.Args {
+		if !found[key] {
+			return fmt.Errorf("[%s] %s is not a theme file in %s", colors.Green(ctx.Env.Name), key, ctx.Env.Directory)
+		}
+	}
+
+	for _, key := range ctx.Args {
+		dependents := graph.Dependents(key)
+		if len(dependents) == 0 {
+			ctx.Log.Printf("%s is not used by any template or layout", colors.Blue(key))
+			continue
+		}
+		ctx.Log.Printf("%s is used by:", colors.Blue(key))
+		for _, dependent := range dependents {
+			ctx.Log.Printf("\t%s", dependent)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShowRefs(t *testing.T) {
+	dir := createThemeDir(t, map[string]string{
+		"layout/theme.liquid":      "{{ content_for_layout }}\n{% render 'icon' %}",
+		"templates/product.liquid": "{% render 'price' %}",
+		"snippets/icon.liquid":     "<svg></svg>",
+		"snippets/old.liquid":      "old",
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, _, _, stdOut, _ := createTestCtx()
+	ctx.Env.Directory = dir
+	assert.Nil(t, showRefs(ctx))
+	assert.Contains(t, stdOut.String(), filepath.Join("templates", "product.liquid")+":1: snippets/price.liquid does not exist")
+	assert.Contains(t, stdOut.String(), filepath.Join("snippets", "old.liquid")+": is not used by any template or layout")
+	assert.Contains(t, stdOut.String(), "1 missing references, 1 unused files")
+
+	stdOut.Reset()
+	ctx.Args = []string{"snippets/icon.liquid", "snippets/old.liquid"}
+	assert.Nil(t, showRefs(ctx))
+	assert.Contains(t, stdOut.String(), "is used by:\n\tlayout/theme.liquid")
+	assert.Contains(t, stdOut.String(), "is not used by any template or layout")
+
+	ctx.Args = []string{"snippets/nope.liquid"}
+	err := showRefs(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "snippets/nope.liquid is not a theme file")
+	}
+
+	ctx.Env.Directory = filepath.Join(dir, "nope")
+	assert.NotNil(t, showRefs(ctx))
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
 
 	"github.com/spf13/cobra"
 
@@ -34,17 +33,16 @@ func remove(ctx *cmdutil.Ctx, removeFile func(string) error) error {
 		return fmt.Errorf("[%s] please specify file(s) to be removed", colors.Green(ctx.Env.Name))
 	}
 
-	var removeGroup sync.WaitGroup
-	ctx.StartProgress(len(ctx.Args))
+	tasks := []cmdutil.Task{}
 	for _, filename := range ctx.Args {
-		removeGroup.Add(1)
-		go func(filename string) {
-			defer removeGroup.Done()
-			perform(ctx, filename, file.Remove, "")
-			removeFile(filepath.Join(ctx.Env.Directory, filename))
-		}(filename)
+		tasks = append(tasks, cmdutil.Task{Path: filename, Op: file.Remove})
 	}
 
-	removeGroup.Wait()
+	ctx.StartProgress(len(tasks))
+	ctx.Run(tasks, func(task cmdutil.Task) file.Op {
+		perform(ctx, task.Path, task.Op, "")
+		removeFile(filepath.Join(ctx.Env.Directory, task.Path))
+		return task.Op
+	})
 	return nil
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+)
+
+// failedPaths will return the paths of the files that failed the last time the
+// command ran, so that only they are retried
+func failedPaths(ctx *cmdutil.Ctx, command string) ([]string, error) {
+	failures, err := ctx.State.Failures(command)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] could not read the files that failed to %s: %s", colors.Green(ctx.Env.Name), command, err)
+	} else if len(failures) == 0 {
+		return nil, fmt.Errorf("[%s] there are no failed files to retry, the last %s did not fail", colors.Green(ctx.Env.Name), command)
+	}
+	paths := []string{}
+	for _, failure := range failures {
+		paths = append(paths, failure.Path)
+	}
+	return paths, nil
+}
+
+// saveFailures will record the files that failed while running the command,
+// replacing the failures of the last time it ran
+func saveFailures(ctx *cmdutil.Ctx, command string) {
+	failures := ctx.Failures()
+	if err := ctx.State.SaveFailures(command, failures); err != nil {
+		ctx.ErrLog.Printf("[%s] could not record the files that failed, they cannot be retried with --retry-failed: %s", colors.Green(ctx.Env.Name), err)
+	} else if len(failures) > 0 {
+		ctx.Log.Printf("[%s] Pass --retry-failed to %s only the %d files that failed", colors.Green(ctx.Env.Name), command, len(failures))
+	}
+}
@@ -110,26 +110,59 @@ func init() {
 	openCmd.Flags().BoolVarP(&flags.AllEnvs, "allenvs", "a", false, "run command with all environments")
 	downloadCmd.Flags().BoolVarP(&flags.AllEnvs, "allenvs", "a", false, "run command with all environments")
 	deployCmd.Flags().BoolVarP(&flags.AllEnvs, "allenvs", "a", false, "run command with all environments")
+	deployCmd.Flags().IntVar(&flags.Concurrency, "concurrency", 0, "how many files to upload at the same time. This will override what is in your config.yml (default 10)")
+	downloadCmd.Flags().IntVar(&flags.Concurrency, "concurrency", 0, "how many files to download at the same time. This will override what is in your config.yml (default 10)")
+	removeCmd.Flags().IntVar(&flags.Concurrency, "concurrency", 0, "how many files to remove at the same time. This will override what is in your config.yml (default 10)")
 	updateCmd.Flags().StringVar(&flags.Version, "version", "latest", "version of themekit to install")
 	newCmd.Flags().StringVarP(&flags.Name, "name", "n", "", "a name to define your theme on your shopify admin")
+	newCmd.Flags().StringVar(&flags.Template, "template", "", "create the theme from this starter template directory, archive or template name, filling in its [[ .Name ]], [[ .Store ]] and [[ .Locale ]] placeholders. No other template syntax is supported.")
+	newCmd.Flags().StringVar(&flags.Locale, "locale", "en", "the locale filled into the [[ .Locale ]] placeholders of a starter template")
+	newCmd.Flags().StringVar(&flags.OnExists, "on-exists", "", "what to do with files that already exist in the project directory: skip, overwrite, backup or prompt. (default skip)")
+	newCmd.Flags().StringVar(&flags.From, "from", "", "create the theme from this .zip, .tar.gz or .tgz archive or directory instead of the blank template")
 	openCmd.Flags().BoolVarP(&flags.Edit, "edit", "E", false, "open the web editor for the theme.")
 	openCmd.Flags().StringVarP(&flags.With, "browser", "b", "", "name of the browser to open the url. the name should match the name of browser on your system.")
 	getCmd.Flags().BoolVarP(&flags.List, "list", "l", false, "list available themes.")
 	deployCmd.Flags().BoolVarP(&flags.NoDelete, "nodelete", "n", false, "do not delete files on shopify during deploy.")
+	downloadCmd.Flags().StringVar(&flags.OnConflict, "on-conflict", "", "what to do with files changed both locally and on shopify since the last sync: stop, keep-both or overwrite. (default stop)")
+	deployCmd.Flags().BoolVar(&flags.Resume, "resume", false, "finish the file operations of a deploy that did not finish.")
+	downloadCmd.Flags().BoolVar(&flags.Resume, "resume", false, "finish the downloads of a download that did not finish.")
+	deployCmd.Flags().BoolVar(&flags.RetryFailed, "retry-failed", false, "only deploy the files that failed to deploy the last time.")
+	downloadCmd.Flags().BoolVar(&flags.RetryFailed, "retry-failed", false, "only download the files that failed to download the last time.")
+	downloadCmd.Flags().StringVar(&flags.Archive, "archive", "", "write the files into this .zip, .tar.gz or .tgz archive instead of the project directory.")
+	downloadCmd.Flags().BoolVar(&flags.Prune, "prune", false, "remove local files that were synced before but are no longer on shopify.")
+	downloadCmd.Flags().BoolVar(&flags.PruneUnsynced, "prune-unsynced", false, "with --prune, also remove local files that are not on shopify and were never synced.")
+	downloadCmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "list the files that would be downloaded or pruned without changing anything.")
+	deployCmd.Flags().BoolVar(&flags.StealLock, "steal-lock", false, "take the lock on the theme even if another process holds it.")
+	watchCmd.Flags().BoolVar(&flags.StealLock, "steal-lock", false, "take the lock on the theme even if another process holds it.")
+	deployCmd.Flags().BoolVar(&flags.ForceDelete, "force-delete", false, "remove files from shopify even if more would be removed than max_deletes or max_delete_percent allow.")
+	deployCmd.Flags().BoolVarP(&flags.ForceDelete, "yes", "y", false, "same as --force-delete.")
+	deployCmd.Flags().StringVar(&flags.Since, "since", "", "only deploy the files that were added, changed, renamed or deleted since this git ref.")
+	deployCmd.Flags().BoolVar(&flags.Changed, "changed", false, "use the checksums from the last sync instead of listing the files on shopify, if they were listed recently.")
+	deployCmd.Flags().BoolVar(&flags.ValidateSettings, "validate-settings", false, "check config/settings_data.json and the json templates against their schemas and do not deploy if they are not valid.")
+	localesCheckCmd.Flags().BoolVar(&flags.JSON, "json", false, "print the results as json.")
+	checkCmd.Flags().BoolVar(&flags.SettingsOnly, "settings", false, "only check config/settings_data.json and the json templates against their schemas.")
+	deployCmd.Flags().BoolVar(&flags.MergeSettings, "merge-settings", false, "merge config/settings_data.json with the settings on shopify instead of replacing them.")
+	deployCmd.Flags().StringVar(&flags.OnConflict, "on-conflict", "", "what to do with files changed on shopify since the last sync: skip, overwrite or download. (default skip)")
 	openCmd.Flags().BoolVar(&flags.HidePreviewBar, "hidepb", false, "run command with all environments")
 
 	getCmd.Flags().BoolVar(&flags.Live, "live", false, "will allow themekit to autofill the theme ID as the currently published theme ID")
 	downloadCmd.Flags().BoolVar(&flags.Live, "live", false, "will allow themekit to autofill the theme ID as the currently published theme ID")
 	configureCmd.Flags().BoolVar(&flags.Live, "live", false, "will allow themekit to autofill the theme ID as the currently published theme ID")
 
+	localesCmd.AddCommand(localesCheckCmd)
 	ThemeCmd.AddCommand(
+		checkCmd,
 		configureCmd,
 		deployCmd,
 		downloadCmd,
 		getCmd,
+		infoCmd,
+		localesCmd,
 		newCmd,
 		openCmd,
+		packageCmd,
 		publishCmd,
+		refsCmd,
 		removeCmd,
 		updateCmd,
 		versionCmd,
@@ -5,24 +5,17 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 
+	"github.com/Shopify/themekit/src/check"
 	"github.com/Shopify/themekit/src/cmdutil"
 	"github.com/Shopify/themekit/src/colors"
 	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
 )
 
-// This is the limit of assets that can be uploaded at the same time. This may
-// need to be tweaked in the future.
-const assetLimit = 100
-
-// This sets a hard limit on how many assets are loaded at a single time before
-// being uploaded. This is to protect from memory errors when very large themes
-// are uploaded.
-var assetLimitSemaphore = make(chan struct{}, assetLimit)
-
 var watchCmd = &cobra.Command{
 	Use:   "watch",
 	Short: "Watch directory for changes and update remote theme",
@@ -30,19 +23,24 @@ var watchCmd = &cobra.Command{
 
  run 'theme watch' while you are editing and it will detect create, update and delete events.
 
+ Files larger than the size_budgets in your config are warned about, or are not
+ uploaded if strict_size_budgets is set.
+
+ Watch locks the theme while it runs so that a deploy cannot change the theme at
+ the same time. Pass --steal-lock to take a lock that was left behind by a process
+ that did not finish.
+ The lock is public, so it names its holder with the deployer from your config
+ or the account that started a CI build, and never your user or host.
+
  For more information, refer to https://shopify.dev/tools/theme-kit/command-reference#watch.
  `,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return cmdutil.ForEachClient(flags, args, func(ctx *cmdutil.Ctx) error {
+		return cmdutil.ForEachClient(flags, args, withLock("watch", func(ctx *cmdutil.Ctx) error {
 			ctx.DisableSummary()
 
-			checksums := map[string]string{}
-			remoteFiles, err := ctx.Client.GetAllAssets()
+			checksums, err := watchChecksums(ctx)
 			if err != nil {
-				return fmt.Errorf("[%s] Error while fetching info from server: %v", colors.Green(ctx.Env.Name), err)
-			}
-			for _, remoteAsset := range remoteFiles {
-				checksums[remoteAsset.Key] = remoteAsset.Checksum
+				return err
 			}
 
 			watcher, err := file.NewWatcher(ctx.Env, ctx.Flags.ConfigPath, checksums)
@@ -58,10 +56,29 @@ var watchCmd = &cobra.Command{
 			notifier := newNotifyAdapter(ctx.Env.Notify)
 
 			return watch(ctx, watcher.Events, signalChan, notifier)
-		})
+		}))
 	},
 }
 
+// watchChecksums will return the checksums of the files on shopify, using the
+// checksums from the last sync if the files were listed recently.
+func watchChecksums(ctx *cmdutil.Ctx) (map[string]string, error) {
+	if ctx.State.Fresh(stateMaxAge) {
+		return ctx.State.Checksums(), nil
+	}
+
+	checksums := map[string]string{}
+	remoteFiles, err := ctx.Client.GetAllAssets()
+	if err != nil {
+		return checksums, fmt.Errorf("[%s] Error while fetching info from server: %v", colors.Green(ctx.Env.Name), err)
+	}
+	ctx.State.Listed()
+	for _, remoteAsset := range remoteFiles {
+		checksums[remoteAsset.Key] = remoteAsset.Checksum
+	}
+	return checksums, nil
+}
+
 func watch(ctx *cmdutil.Ctx, events chan file.Event, sig chan os.Signal, notifier notifyAdapter) error {
 	// watch should output every action that it is taking and not use a progress bar
 	ctx.Flags.Verbose = true
@@ -69,6 +86,8 @@ func watch(ctx *cmdutil.Ctx, events chan file.Event, sig chan os.Signal, notifie
 
 	if ctx.Env.ReadOnly {
 		return fmt.Errorf("[%s] environment is reaonly", colors.Green(ctx.Env.Name))
+	} else if _, err := check.NewBudgets(ctx.Env.SizeBudgets); err != nil {
+		return fmt.Errorf("[%s] %s", colors.Green(ctx.Env.Name), err)
 	}
 
 	ctx.Log.Printf(
@@ -86,6 +105,7 @@ func watch(ctx *cmdutil.Ctx, events chan file.Event, sig chan os.Signal, notifie
 			}
 			ctx.Log.Printf("[%s] processing %s", colors.Green(ctx.Env.Name), colors.Blue(event.Path))
 			perform(ctx, event.Path, event.Op, event.LastKnownChecksum)
+			ctx.DoneTask(event.Op)
 			if event.Op != file.Skip {
 				notifier.notify(ctx, event.Path)
 			}
@@ -95,44 +115,68 @@ func watch(ctx *cmdutil.Ctx, events chan file.Event, sig chan os.Signal, notifie
 	}
 }
 
-func perform(ctx *cmdutil.Ctx, path string, op file.Op, checksum string) {
-	defer ctx.DoneTask(op)
-
+// perform will carry out a single file operation, reporting any error to the
+// context. The error is returned so that callers can stop dependent operations.
+func perform(ctx *cmdutil.Ctx, path string, op file.Op, checksum string) error {
 	switch op {
+	case file.Conflict:
+		ctx.Conflict(path)
 	case file.Skip:
 		if ctx.Flags.Verbose {
-			localAsset, _ := shopify.ReadAsset(ctx.Env, path)
-			checksumOutput := "Checksum: " + localAsset.Checksum
+			localChecksum, _ := shopify.ReadChecksum(ctx.Env, ctx.State, path)
+			checksumOutput := "Checksum: " + localChecksum
 			ctx.Log.Printf("[%s] %s %s (%s)", colors.Green(ctx.Env.Name), colors.Cyan("Skipped"), colors.Blue(path), checksumOutput)
 		}
 	case file.Remove:
 		if err := ctx.Client.DeleteAsset(shopify.Asset{Key: path}); err != nil {
 			ctx.Err("[%s] (%s) %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
-		} else if ctx.Flags.Verbose {
+			return err
+		}
+		ctx.State.Forget(path)
+		if ctx.Flags.Verbose {
 			ctx.Log.Printf("[%s] Deleted %s", colors.Green(ctx.Env.Name), colors.Blue(path))
 		}
+	case file.Prune:
+		if err := os.Remove(filepath.Join(ctx.Env.Directory, filepath.FromSlash(path))); err != nil && !os.IsNotExist(err) {
+			ctx.Err("[%s] error pruning %s: %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+			return err
+		}
+		ctx.State.Forget(path)
+		if ctx.Flags.Verbose {
+			ctx.Log.Printf("[%s] Pruned %s", colors.Green(ctx.Env.Name), colors.Blue(path))
+		}
 	case file.Get:
-		if asset, err := ctx.Client.GetAsset(path); err != nil {
+		asset, err := ctx.Client.GetAsset(path)
+		if err != nil {
 			ctx.Err("[%s] error downloading %s: %s", colors.Green(ctx.Env.Name), colors.Blue(path), err)
+			return err
 		} else if err = asset.Write(ctx.Env.Directory); err != nil {
 			ctx.Err("[%s] error writing %s: %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key), err)
-		} else if ctx.Flags.Verbose {
+			return err
+		}
+		recordSync(ctx, asset)
+		if ctx.Flags.Verbose {
 			ctx.Log.Printf("[%s] Successfully wrote %s to disk", colors.Green(ctx.Env.Name), colors.Blue(asset.Key))
 		}
 	default:
-		assetLimitSemaphore <- struct{}{}
-		defer func() { <-assetLimitSemaphore }()
-
 		asset, err := shopify.ReadAsset(ctx.Env, path)
 		if err != nil {
 			ctx.Err("[%s] error loading %s: %s", colors.Green(ctx.Env.Name), colors.Green(path), colors.Red(err))
-			return
+			return err
+		}
+		if err = checkFileBudget(ctx, asset); err != nil {
+			ctx.Err("[%s] (%s) not uploaded because it %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key), err)
+			return err
 		}
 
 		if err = ctx.Client.UpdateAsset(asset, checksum); err != nil {
 			ctx.Err("[%s] (%s) %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key), err)
-		} else if ctx.Flags.Verbose {
+			return err
+		}
+		recordSync(ctx, asset)
+		if ctx.Flags.Verbose {
 			ctx.Log.Printf("[%s] Updated %s", colors.Green(ctx.Env.Name), colors.Blue(asset.Key))
 		}
 	}
+	return nil
 }
@@ -27,6 +27,13 @@ func TestWatch(t *testing.T) {
 		assert.Contains(t, err.Error(), "environment is reaonly")
 	}
 
+	ctx, _, _, _, _ = createTestCtx()
+	ctx.Env.SizeBudgets = map[string]string{"assets/*": "big"}
+	err = watch(ctx, make(chan file.Event), make(chan os.Signal), nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid size budget for assets/*")
+	}
+
 	eventChan := make(chan file.Event, 1)
 	ctx, _, _, stdOut, _ := createTestCtx()
 	ctx.Flags.ConfigPath = "config.yml"
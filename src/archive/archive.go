@@ -0,0 +1,168 @@
+// Package archive writes theme files into, and reads them out of, a zip or gzipped
+// tar archive so that a theme can be moved around without unpacking it by hand.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupportedFormat is returned when the archive name does not end in .zip,
+// .tar.gz or .tgz
+var ErrUnsupportedFormat = errors.New("unsupported archive format, the archive name must end in .zip, .tar.gz or .tgz")
+
+// Writer adds files to an archive. It is safe to add files from more than one
+// goroutine at a time.
+type Writer struct {
+	file    *os.File
+	closers []io.Closer
+	add     func(name string, contents []byte, modTime time.Time) error
+	mu      sync.Mutex
+}
+
+// Create will create the archive at the path, choosing the format from its name
+func Create(path string) (*Writer, error) {
+	isZip := strings.HasSuffix(path, ".zip")
+	if !isZip && !strings.HasSuffix(path, ".tar.gz") && !strings.HasSuffix(path, ".tgz") {
+		return nil, ErrUnsupportedFormat
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{file: file}
+	if isZip {
+		zw := zip.NewWriter(file)
+		w.closers = []io.Closer{zw}
+		w.add = zipAdder(zw)
+	} else {
+		gw := gzip.NewWriter(file)
+		tw := tar.NewWriter(gw)
+		w.closers = []io.Closer{tw, gw}
+		w.add = tarAdder(tw)
+	}
+	return w, nil
+}
+
+// Add will write a file to the archive with the name, which should use forward
+// slashes to keep the directory structure.
+func (w *Writer) Add(name string, contents []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.add(name, contents, time.Now())
+}
+
+// Close will finish writing the archive and close its file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, closer := range w.closers {
+		if err := closer.Close(); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+// Each will call found with the name and contents of every file in the archive at
+// the path, choosing the format from its name. Directories are left out.
+func Each(path string, found func(name string, contents io.Reader) error) error {
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		return eachZip(path, found)
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return eachTar(path, found)
+	}
+	return ErrUnsupportedFormat
+}
+
+func eachZip(path string, found func(string, io.Reader) error) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = found(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func eachTar(path string, found func(string, io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := found(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func zipAdder(zw *zip.Writer) func(string, []byte, time.Time) error {
+	return func(name string, contents []byte, modTime time.Time) error {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.Modified = modTime
+		f, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(contents)
+		return err
+	}
+}
+
+func tarAdder(tw *tar.Writer) func(string, []byte, time.Time) error {
+	return func(name string, contents []byte, modTime time.Time) error {
+		header := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(contents)
+		return err
+	}
+}
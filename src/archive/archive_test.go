@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var files = map[string]string{
+	"assets/app.js":                    "var a;",
+	"templates/customers/login.liquid": "login",
+}
+
+func TestCreateZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "theme.zip")
+	writeArchive(t, path)
+
+	r, err := zip.OpenReader(path)
+	assert.Nil(t, err)
+	defer r.Close()
+	found := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		assert.Nil(t, err)
+		data, err := ioutil.ReadAll(rc)
+		assert.Nil(t, err)
+		rc.Close()
+		found[f.Name] = string(data)
+	}
+	assert.Equal(t, files, found)
+}
+
+func TestCreateTarGz(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"theme.tar.gz", "theme.tgz"} {
+		path := filepath.Join(dir, name)
+		writeArchive(t, path)
+
+		f, err := os.Open(path)
+		assert.Nil(t, err)
+		gr, err := gzip.NewReader(f)
+		assert.Nil(t, err)
+		tr := tar.NewReader(gr)
+		found := map[string]string{}
+		for header, err := tr.Next(); err == nil; header, err = tr.Next() {
+			data, err := ioutil.ReadAll(tr)
+			assert.Nil(t, err)
+			found[header.Name] = string(data)
+		}
+		f.Close()
+		assert.Equal(t, files, found, name)
+	}
+}
+
+func TestCreateUnsupported(t *testing.T) {
+	_, err := Create("theme.rar")
+	assert.Equal(t, ErrUnsupportedFormat, err)
+
+	_, err = Create(filepath.Join("nope", "theme.zip"))
+	assert.NotNil(t, err)
+}
+
+func TestEach(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"theme.zip", "theme.tar.gz", "theme.tgz"} {
+		path := filepath.Join(dir, name)
+		writeArchive(t, path)
+		found := map[string]string{}
+		err := Each(path, func(name string, contents io.Reader) error {
+			data, err := ioutil.ReadAll(contents)
+			found[name] = string(data)
+			return err
+		})
+		assert.Nil(t, err, name)
+		assert.Equal(t, files, found, name)
+
+		stopErr := errors.New("stop")
+		assert.Equal(t, stopErr, Each(path, func(string, io.Reader) error { return stopErr }), name)
+	}
+
+	assert.Equal(t, ErrUnsupportedFormat, Each("theme.rar", nil))
+	assert.NotNil(t, Each(filepath.Join(dir, "nope.zip"), nil))
+	assert.NotNil(t, Each(filepath.Join(dir, "nope.tgz"), nil))
+}
+
+func writeArchive(t *testing.T, path string) {
+	w, err := Create(path)
+	assert.Nil(t, err)
+	for name, contents := range files {
+		assert.Nil(t, w.Add(name, []byte(contents)))
+	}
+	assert.Nil(t, w.Close())
+}
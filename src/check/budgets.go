@@ -0,0 +1,103 @@
+package check
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ryanuber/go-glob"
+)
+
+// TotalBudget is the size budget pattern that limits the size of the whole theme
+const TotalBudget = "total"
+
+// sizeUnits are the units a budget size can be written in
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"B", 1},
+}
+
+type budget struct {
+	pattern string
+	limit   int64
+}
+
+// Budgets are limits on the size of theme files, from the size_budgets config
+type Budgets struct {
+	files []budget
+	total int64
+}
+
+// NewBudgets will parse the size budgets from the config. Each key is a glob
+// pattern of theme file names, like assets/*.js, or total for the whole theme, and
+// each value is a size like 200KB. A budget of less than a byte is an error rather
+// than no budget at all.
+func NewBudgets(config map[string]string) (*Budgets, error) {
+	b := &Budgets{}
+	for pattern, size := range config {
+		limit, err := ParseSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size budget for %s: %s", pattern, err)
+		} else if limit < 1 {
+			return nil, fmt.Errorf("invalid size budget for %s: %q is less than a byte", pattern, size)
+		}
+		if pattern == TotalBudget {
+			b.total = limit
+		} else {
+			b.files = append(b.files, budget{pattern: pattern, limit: limit})
+		}
+	}
+	sort.Slice(b.files, func(i, j int) bool { return b.files[i].pattern < b.files[j].pattern })
+	return b, nil
+}
+
+// File will return a problem for every budget that the file is over
+func (b *Budgets) File(key string, size int64) []Problem {
+	problems := []Problem{}
+	for _, budget := range b.files {
+		if size > budget.limit && glob.Glob(budget.pattern, key) {
+			problems = append(problems, Problem{
+				Key:     key,
+				Message: fmt.Sprintf("is %s, over the %s budget for %s", byteSize(size), byteSize(budget.limit), budget.pattern),
+			})
+		}
+	}
+	return problems
+}
+
+// Total will return a problem if the theme is over the total budget
+func (b *Budgets) Total(size int64) []Problem {
+	if b.total == 0 || size <= b.total {
+		return []Problem{}
+	}
+	return []Problem{{Message: fmt.Sprintf("the theme is %s, over the %s total budget", byteSize(size), byteSize(b.total))}}
+}
+
+// HasTotal will return true if there is a budget for the whole theme
+func (b *Budgets) HasTotal() bool {
+	return b.total > 0
+}
+
+// ParseSize will parse a size like 200KB, 1.5MB or 1024 into bytes. Units are
+// powers of 1024 and are not case sensitive.
+func ParseSize(size string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.bytes
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%q is not a size like 200KB", size)
+	}
+	return int64(value * float64(multiplier)), nil
+}
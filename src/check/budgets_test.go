@@ -0,0 +1,72 @@
+package check
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBudgets(t *testing.T) {
+	budgets, err := NewBudgets(map[string]string{"assets/*.js": "200KB", "*.jpg": "0.5mb", "total": "20MB"})
+	assert.Nil(t, err)
+	assert.True(t, budgets.HasTotal())
+	assert.Equal(t, []budget{{pattern: "*.jpg", limit: 512 * 1024}, {pattern: "assets/*.js", limit: 200 * 1024}}, budgets.files)
+
+	budgets, err = NewBudgets(nil)
+	assert.Nil(t, err)
+	assert.False(t, budgets.HasTotal())
+	assert.Equal(t, []Problem{}, budgets.File("assets/app.js", MaxAssetSize))
+
+	_, err = NewBudgets(map[string]string{"assets/*.js": "big"})
+	assert.EqualError(t, err, `invalid size budget for assets/*.js: "big" is not a size like 200KB`)
+
+	// a budget of nothing is a mistake, not a way to turn the budget off
+	for _, size := range []string{"0B", "0", "-1KB"} {
+		_, err = NewBudgets(map[string]string{"total": size})
+		assert.EqualError(t, err, fmt.Sprintf(`invalid size budget for total: %q is not a size like 200KB`, size), size)
+	}
+	_, err = NewBudgets(map[string]string{"total": "0.5B"})
+	assert.EqualError(t, err, `invalid size budget for total: "0.5B" is less than a byte`)
+}
+
+func TestBudgets_File(t *testing.T) {
+	budgets, err := NewBudgets(map[string]string{"assets/*.js": "200KB", "*.jpg": "500KB", "assets/*": "1MB"})
+	assert.Nil(t, err)
+	assert.Equal(t, []Problem{}, budgets.File("assets/app.js", 200*1024))
+	assert.Equal(t, []Problem{{Key: "assets/app.js", Message: "is 300.0KB, over the 200.0KB budget for assets/*.js"}}, budgets.File("assets/app.js", 300*1024))
+	assert.Equal(t, []Problem{
+		{Key: "assets/hero.jpg", Message: "is 2.0MB, over the 500.0KB budget for *.jpg"},
+		{Key: "assets/hero.jpg", Message: "is 2.0MB, over the 1.0MB budget for assets/*"},
+	}, budgets.File("assets/hero.jpg", 2*1024*1024))
+}
+
+func TestBudgets_Total(t *testing.T) {
+	budgets, err := NewBudgets(map[string]string{"total": "1MB"})
+	assert.Nil(t, err)
+	assert.Equal(t, []Problem{}, budgets.Total(1024*1024))
+	assert.Equal(t, []Problem{{Message: "the theme is 1.5MB, over the 1.0MB total budget"}}, budgets.Total(1536*1024))
+}
+
+func TestParseSize(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"1024":    1024,
+		"10B":     10,
+		"200KB":   200 * 1024,
+		"200 kb":  200 * 1024,
+		"1.5MB":   1536 * 1024,
+		"1GB":     1024 * 1024 * 1024,
+		" 2mb ":   2 * 1024 * 1024,
+		"0.5 KB":  512,
+		"100.0kB": 100 * 1024,
+	} {
+		size, err := ParseSize(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, size, input)
+	}
+
+	for _, input := range []string{"", "KB", "-1KB", "0", "big", "1TB"} {
+		_, err := ParseSize(input)
+		assert.NotNil(t, err, input)
+	}
+}
@@ -0,0 +1,202 @@
+// Package check finds the problems in theme files that shopify would reject them
+// for, without connecting to shopify, so that they can be fixed before a deploy.
+package check
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+// These are the largest files that shopify will accept. Templates, sections,
+// snippets, locales and config have a smaller limit than the files in assets.
+const (
+	MaxAssetSize    = 20 * 1024 * 1024
+	MaxTemplateSize = 256 * 1024
+)
+
+// requiredFiles are the files that shopify will not accept a theme without. Each
+// entry lists the files that can fulfill the requirement.
+var requiredFiles = [][]string{
+	{"layout/theme.liquid"},
+	{"config/settings_schema.json"},
+	{"config/settings_data.json"},
+	{"templates/index.liquid", "templates/index.json"},
+}
+
+// Problem is something wrong with a theme file. Line is 0 when the problem is with
+// the whole file, and Key is empty when the problem is with the whole theme.
+type Problem struct {
+	Key     string
+	Line    int
+	Message string
+}
+
+// String will format the problem the way a compiler would, as file:line: message
+func (p Problem) String() string {
+	switch {
+	case p.Key == "":
+		return p.Message
+	case p.Line == 0:
+		return fmt.Sprintf("%s: %s", p.Key, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.Key, p.Line, p.Message)
+}
+
+// Theme will check every file in the project directory of the environment that is
+// not ignored, the theme that they make up, its settings and its size budgets. The
+// problems are sorted by file and line.
+func Theme(e *env.Env) ([]Problem, error) {
+	keys, err := shopify.AssetKeys(e)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := NewBudgets(e.SizeBudgets)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := Structure(keys)
+	settingsProblems, err := Settings(keys, func(key string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(e.Directory, filepath.FromSlash(key)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, settingsProblems...)
+
+	var total int64
+	for _, key := range keys {
+		info, err := os.Stat(filepath.Join(e.Directory, filepath.FromSlash(key)))
+		if err != nil {
+			return nil, err
+		}
+		var contents []byte
+		if hasSyntax(key) && info.Size() <= sizeLimit(key) {
+			if contents, err = ioutil.ReadFile(filepath.Join(e.Directory, filepath.FromSlash(key))); err != nil {
+				return nil, err
+			}
+		}
+		problems = append(problems, File(key, contents, info.Size())...)
+		problems = append(problems, budgets.File(key, info.Size())...)
+		total += info.Size()
+	}
+	problems = append(problems, budgets.Total(total)...)
+
+	Sort(problems)
+	return problems, nil
+}
+
+// Structure will check that the files with the keys make up a theme that shopify
+// will accept. It does not look at what is in the files.
+func Structure(keys []string) []Problem {
+	problems := []Problem{}
+	found := map[string]bool{}
+	for _, key := range keys {
+		found[key] = true
+	}
+	for _, options := range requiredFiles {
+		missing := true
+		for _, option := range options {
+			missing = missing && !found[option]
+		}
+		if missing {
+			problems = append(problems, Problem{Message: "missing " + strings.Join(options, " or ")})
+		}
+	}
+	for _, key := range keys {
+		if path.Ext(key) == ".liquid" && found[strings.TrimSuffix(key, ".liquid")] {
+			problems = append(problems, Problem{
+				Key:     key,
+				Message: fmt.Sprintf("conflicts with %s, both compile to the same file", strings.TrimSuffix(key, ".liquid")),
+			})
+		}
+	}
+	return problems
+}
+
+// File will check a single theme file. The contents are only needed for liquid
+// and json files, size is the size of the file in bytes.
+func File(key string, contents []byte, size int64) []Problem {
+	if !file.IsAssetKey(key) {
+		return []Problem{{Key: key, Message: "is not in a theme directory, shopify will not accept it"}}
+	} else if dir := path.Dir(key); !file.IsAssetDir(dir) {
+		return []Problem{{Key: key, Message: fmt.Sprintf("is in %s, shopify only accepts files directly inside of a theme directory", dir)}}
+	}
+
+	if limit := sizeLimit(key); size > limit {
+		return []Problem{{Key: key, Message: fmt.Sprintf("is %s, larger than the %s that shopify allows", byteSize(size), byteSize(limit))}}
+	}
+
+	switch path.Ext(key) {
+	case ".json":
+		if problem, ok := checkJSON(key, contents, 1); !ok {
+			return []Problem{problem}
+		}
+	case ".liquid":
+		return checkLiquid(key, contents)
+	}
+	return nil
+}
+
+// Sort will sort the problems with the problems for the whole theme first, and
+// then by file and line
+func Sort(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Key != problems[j].Key {
+			return problems[i].Key < problems[j].Key
+		}
+		return problems[i].Line < problems[j].Line
+	})
+}
+
+// sizeLimit will return the largest size that shopify allows for the file. Files
+// that are rendered by shopify, rather than served as they are, have a smaller
+// limit.
+func sizeLimit(key string) int64 {
+	if hasSyntax(key) && !strings.HasPrefix(key, "assets/") {
+		return MaxTemplateSize
+	}
+	return MaxAssetSize
+}
+
+// hasSyntax will return true for the files whose contents are checked
+func hasSyntax(key string) bool {
+	return path.Ext(key) == ".liquid" || path.Ext(key) == ".json"
+}
+
+func byteSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// Size will return the size in bytes of all of the files in the project directory
+// of the environment that are not ignored
+func Size(e *env.Env) (int64, error) {
+	keys, err := shopify.AssetKeys(e)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, key := range keys {
+		info, err := os.Stat(filepath.Join(e.Directory, filepath.FromSlash(key)))
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
@@ -0,0 +1,111 @@
+package check
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/env"
+)
+
+func TestProblem_String(t *testing.T) {
+	assert.Equal(t, "missing layout/theme.liquid", Problem{Message: "missing layout/theme.liquid"}.String())
+	assert.Equal(t, "README.md: is wrong", Problem{Key: "README.md", Message: "is wrong"}.String())
+	assert.Equal(t, "layout/theme.liquid:3: if is not closed", Problem{Key: "layout/theme.liquid", Line: 3, Message: "if is not closed"}.String())
+}
+
+func TestTheme(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-check")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for key, contents := range map[string]string{
+		"layout/theme.liquid":         "{% if true %}\n{{ content_for_layout }}\n",
+		"config/settings_schema.json": "[]",
+		"config/settings_data.json":   "{\n  \"current\": \n}",
+		"assets/app.js":               "var a;",
+		"assets/app.js.liquid":        "var a;",
+		"README.md":                   "# theme",
+		"snippets/icons/cart.liquid":  "<svg></svg>",
+		".themekit/state.json":        "{}",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+
+	problems, err := Theme(&env.Env{Directory: dir, SizeBudgets: map[string]string{"assets/*.js": "4B", "total": "10B"}})
+	assert.Nil(t, err)
+	found := []string{}
+	for _, problem := range problems {
+		found = append(found, problem.String())
+	}
+	assert.Equal(t, []string{
+		"missing templates/index.liquid or templates/index.json",
+		"the theme is 81B, over the 10B total budget",
+		"assets/app.js: is 6B, over the 4B budget for assets/*.js",
+		"assets/app.js.liquid: conflicts with assets/app.js, both compile to the same file",
+		"config/settings_data.json:3: invalid json, invalid character '}' looking for beginning of value",
+		"layout/theme.liquid:1: if is not closed",
+		"snippets/icons/cart.liquid: is in snippets/icons, shopify only accepts files directly inside of a theme directory",
+	}, found)
+
+	_, err = Theme(&env.Env{Directory: dir, SizeBudgets: map[string]string{"total": "big"}})
+	assert.NotNil(t, err)
+
+	_, err = Theme(&env.Env{Directory: filepath.Join(dir, "nope")})
+	assert.NotNil(t, err)
+}
+
+func TestSize(t *testing.T) {
+	size, err := Size(&env.Env{Directory: filepath.Join("..", "shopify", "_testdata", "project")})
+	assert.Nil(t, err)
+	assert.True(t, size > 0)
+
+	_, err = Size(&env.Env{Directory: "nope"})
+	assert.NotNil(t, err)
+}
+
+func TestStructure(t *testing.T) {
+	keys := []string{
+		"layout/theme.liquid",
+		"config/settings_schema.json",
+		"config/settings_data.json",
+		"templates/index.json",
+	}
+	assert.Equal(t, []Problem{}, Structure(keys))
+	assert.Equal(t, []Problem{
+		{Message: "missing layout/theme.liquid"},
+		{Message: "missing templates/index.liquid or templates/index.json"},
+		{Key: "assets/app.js.liquid", Message: "conflicts with assets/app.js, both compile to the same file"},
+	}, Structure([]string{"config/settings_schema.json", "config/settings_data.json", "assets/app.js", "assets/app.js.liquid"}))
+}
+
+func TestFile(t *testing.T) {
+	assert.Empty(t, File("assets/logo.png", nil, 1024))
+	assert.Empty(t, File("templates/index.json", []byte("/* generated */\n{}"), 18))
+	assert.Empty(t, File("snippets/a.liquid", []byte("{% if a %}{% endif %}"), 21))
+
+	assert.Equal(t, []Problem{{Key: "notes.txt", Message: "is not in a theme directory, shopify will not accept it"}}, File("notes.txt", nil, 1))
+	assert.Equal(t, []Problem{{Key: "assets/video.mp4", Message: "is 20.5MB, larger than the 20.0MB that shopify allows"}}, File("assets/video.mp4", nil, MaxAssetSize+512*1024))
+	assert.Equal(t, []Problem{{Key: "snippets/big.liquid", Message: "is 257.0KB, larger than the 256.0KB that shopify allows"}}, File("snippets/big.liquid", nil, MaxTemplateSize+1024))
+	assert.Empty(t, File("assets/big.js.liquid", []byte("var a;"), MaxTemplateSize+1024))
+	assert.Equal(t, []Problem{{Key: "locales/en.json", Line: 2, Message: "invalid json, unexpected end of JSON input"}}, File("locales/en.json", []byte("{\n"), 2))
+}
+
+func TestCheckJSON(t *testing.T) {
+	_, ok := checkJSON("templates/index.json", []byte("/*\n * generated\n */\n{\"sections\": {}}"), 1)
+	assert.True(t, ok)
+
+	problem, ok := checkJSON("templates/index.json", []byte("/*\n * generated\n */\n{\"sections\": {},}"), 1)
+	assert.False(t, ok)
+	assert.Equal(t, 4, problem.Line)
+	assert.True(t, strings.HasPrefix(problem.Message, "invalid json"))
+
+	problem, _ = checkJSON("sections/a.liquid", []byte("\n{\n\"name\" 1}"), 10)
+	assert.Equal(t, 12, problem.Line)
+}
@@ -0,0 +1,234 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// blockTags are the liquid tags that have to be closed with an end tag
+var blockTags = map[string]bool{
+	"capture":    true,
+	"case":       true,
+	"comment":    true,
+	"for":        true,
+	"form":       true,
+	"if":         true,
+	"javascript": true,
+	"paginate":   true,
+	"raw":        true,
+	"schema":     true,
+	"style":      true,
+	"stylesheet": true,
+	"tablerow":   true,
+	"unless":     true,
+}
+
+// textTags are the block tags whose contents are not liquid, so no tags are looked
+// for inside of them until they are closed
+var textTags = map[string]bool{
+	"comment":    true,
+	"javascript": true,
+	"raw":        true,
+	"schema":     true,
+	"stylesheet": true,
+}
+
+// branchTags are the tags that split a block and the blocks they can be used in
+var branchTags = map[string][]string{
+	"else":  {"if", "unless", "case", "for"},
+	"elsif": {"if", "unless"},
+	"when":  {"case"},
+}
+
+var (
+	delimPattern   = regexp.MustCompile(`\{[{%]`)
+	commentPattern = regexp.MustCompile(`^\s*/\*(?s:.*?)\*/`)
+	endPatterns    = map[string]*regexp.Regexp{}
+)
+
+func init() {
+	for name := range textTags {
+		endPatterns[name] = regexp.MustCompile(`\{%-?\s*end` + name + `\s*-?%\}`)
+	}
+}
+
+type openTag struct {
+	name string
+	line int
+}
+
+type liquidChecker struct {
+	key      string
+	text     string
+	stack    []openTag
+	schemas  int
+	problems []Problem
+}
+
+// checkLiquid will check that every tag and output in a liquid file is closed and
+// that block tags are balanced with their end tags
+func checkLiquid(key string, contents []byte) []Problem {
+	c := &liquidChecker{key: key, text: string(contents), problems: []Problem{}}
+	c.scan()
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		c.problem(c.stack[i].line, "%s is not closed", c.stack[i].name)
+	}
+	return c.problems
+}
+
+func (c *liquidChecker) scan() {
+	for offset := 0; offset < len(c.text); {
+		if top := c.top(); textTags[top.name] {
+			end := endPatterns[top.name].FindStringIndex(c.text[offset:])
+			if end == nil {
+				return
+			}
+			if top.name == "schema" {
+				c.checkSchema(offset, c.text[offset:offset+end[0]])
+			}
+			c.stack = c.stack[:len(c.stack)-1]
+			offset += end[1]
+			continue
+		}
+
+		loc := delimPattern.FindStringIndex(c.text[offset:])
+		if loc == nil {
+			return
+		}
+		start := offset + loc[0]
+		closer, kind := "%}", "tag"
+		if c.text[start+1] == '{' {
+			closer, kind = "}}", "output"
+		}
+		length := strings.Index(c.text[start+2:], closer)
+		if length < 0 {
+			c.problem(c.line(start), "%s is not closed, expected %s", kind, closer)
+			return
+		}
+		offset = start + 2 + length + len(closer)
+		if kind == "tag" {
+			c.tag(c.text[start+2:start+2+length], c.line(start))
+		}
+	}
+}
+
+// tag will handle the markup of a tag, and each line of a {% liquid %} tag
+func (c *liquidChecker) tag(markup string, line int) {
+	markup = strings.Trim(markup, "-")
+	name := tagName(markup)
+	if name != "liquid" {
+		c.handle(name, line)
+		return
+	}
+	for i, statement := range strings.Split(strings.TrimSpace(markup)[len("liquid"):], "\n") {
+		name := tagName(statement)
+		if top := c.top(); textTags[top.name] && name != "end"+top.name {
+			continue
+		}
+		c.handle(name, line+i)
+	}
+}
+
+func (c *liquidChecker) handle(name string, line int) {
+	switch {
+	case blockTags[name]:
+		if name == "schema" {
+			if c.schemas++; c.schemas > 1 {
+				c.problem(line, "only one schema is allowed in a file")
+			}
+		}
+		c.stack = append(c.stack, openTag{name: name, line: line})
+	case strings.HasPrefix(name, "end") && blockTags[name[len("end"):]]:
+		c.close(name[len("end"):], line)
+	case branchTags[name] != nil:
+		top := c.top()
+		for _, parent := range branchTags[name] {
+			if top.name == parent {
+				return
+			}
+		}
+		c.problem(line, "%s is not inside of %s", name, orList(branchTags[name]))
+	}
+}
+
+// close will pop the block that the end tag closes, reporting any blocks inside
+// of it that were not closed
+func (c *liquidChecker) close(name string, line int) {
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		if c.stack[i].name != name {
+			continue
+		}
+		for j := len(c.stack) - 1; j > i; j-- {
+			c.problem(c.stack[j].line, "%s is not closed before the end%s on line %d", c.stack[j].name, name, line)
+		}
+		c.stack = c.stack[:i]
+		return
+	}
+	c.problem(line, "end%s does not close anything, there is no %s before it", name, name)
+}
+
+func (c *liquidChecker) checkSchema(offset int, contents string) {
+	if problem, ok := checkJSON(c.key, []byte(contents), c.line(offset)); !ok {
+		problem.Message = "schema has " + problem.Message
+		c.problems = append(c.problems, problem)
+	}
+}
+
+func (c *liquidChecker) top() openTag {
+	if len(c.stack) == 0 {
+		return openTag{}
+	}
+	return c.stack[len(c.stack)-1]
+}
+
+func (c *liquidChecker) line(offset int) int {
+	return strings.Count(c.text[:offset], "\n") + 1
+}
+
+func (c *liquidChecker) problem(line int, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Key: c.key, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func orList(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+func tagName(markup string) string {
+	fields := strings.Fields(markup)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// checkJSON will check that the contents parse as json, firstLine is the line in
+// the file that the contents start on. Shopify allows a comment at the start of a
+// json file, so one is skipped.
+func checkJSON(key string, contents []byte, firstLine int) (Problem, bool) {
+	if loc := commentPattern.FindIndex(contents); loc != nil {
+		contents = append([]byte{}, contents...)
+		for i := loc[0]; i < loc[1]; i++ {
+			if contents[i] != '\n' {
+				contents[i] = ' '
+			}
+		}
+	}
+
+	var value interface{}
+	err := json.Unmarshal(contents, &value)
+	if err == nil {
+		return Problem{}, true
+	}
+	offset := int64(len(contents))
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		offset = syntaxErr.Offset
+	}
+	line := firstLine + bytes.Count(contents[:offset], []byte("\n"))
+	return Problem{Key: key, Line: line, Message: "invalid json, " + err.Error()}, false
+}
@@ -0,0 +1,57 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLiquid(t *testing.T) {
+	testcases := []struct {
+		contents string
+		problems []string
+	}{
+		{contents: "{% if a %}{% for b in c %}{{ b }}{% else %}{% endfor %}{% elsif d %}{% endif %}"},
+		{contents: "{%- unless a -%}\n{%- endunless -%}"},
+		{contents: "{% case a %}{% when 1 %}{% else %}{% endcase %}"},
+		{contents: "{% raw %}{% if {{ {% endraw %}"},
+		{contents: "{% comment %}{% if a %}{% endcomment %}"},
+		{contents: "{% liquid\n  if a\n    echo b\n  endif\n%}"},
+		{contents: "{% liquid\n  comment\n    if\n  endcomment\n%}"},
+		{contents: "{% schema %}\n{\"name\": \"a\"}\n{% endschema %}"},
+		{contents: "{% if a %}\n\n", problems: []string{"a.liquid:1: if is not closed"}},
+		{contents: "{% endif %}", problems: []string{"a.liquid:1: endif does not close anything, there is no if before it"}},
+		{contents: "{% else %}", problems: []string{"a.liquid:1: else is not inside of if, unless, case or for"}},
+		{contents: "{% capture a %}{% when %}{% endcapture %}", problems: []string{"a.liquid:1: when is not inside of case"}},
+		{
+			contents: "{% for a in b %}\n{% if a %}\n{% endfor %}",
+			problems: []string{"a.liquid:2: if is not closed before the endfor on line 3"},
+		},
+		{contents: "\n{% if a", problems: []string{"a.liquid:2: tag is not closed, expected %}"}},
+		{contents: "{{ a }\n", problems: []string{"a.liquid:1: output is not closed, expected }}"}},
+		{
+			contents: "{% liquid\n  for a in b\n    if a\n  endfor\n%}",
+			problems: []string{"a.liquid:3: if is not closed before the endfor on line 4"},
+		},
+		{contents: "{% raw %}\n{% if %}", problems: []string{"a.liquid:1: raw is not closed"}},
+		{
+			contents: "{% schema %}\n{\n  \"name\": \"a\",\n}\n{% endschema %}",
+			problems: []string{"a.liquid:4: schema has invalid json, invalid character '}' looking for beginning of object key string"},
+		},
+		{
+			contents: "{% schema %}{}{% endschema %}\n{% schema %}{}{% endschema %}",
+			problems: []string{"a.liquid:2: only one schema is allowed in a file"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		found := []string{}
+		for _, problem := range checkLiquid("a.liquid", []byte(testcase.contents)) {
+			found = append(found, problem.String())
+		}
+		if testcase.problems == nil {
+			testcase.problems = []string{}
+		}
+		assert.Equal(t, testcase.problems, found, testcase.contents)
+	}
+}
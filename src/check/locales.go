@@ -0,0 +1,209 @@
+package check
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// translatePattern matches a quoted translation key passed to the t or
+	// translate filter
+	translatePattern = regexp.MustCompile(`['"]([\w.-]+)['"]\s*\|\s*(?:t|translate)\b`)
+	// schemaTranslationPattern matches a translation key used in a schema, like
+	// "t:sections.header.name"
+	schemaTranslationPattern = regexp.MustCompile(`"t:([\w.-]+)"`)
+)
+
+// LocaleReport is how the locale files of a theme compare to their default
+// locales, and which translations the theme uses
+type LocaleReport struct {
+	Default       string         `json:"default"`
+	DefaultSchema string         `json:"default_schema,omitempty"`
+	Locales       []LocaleResult `json:"locales"`
+	Unused        []string       `json:"unused"`
+	UnusedSchema  []string       `json:"unused_schema"`
+	Undefined     []Translation  `json:"undefined"`
+}
+
+// LocaleResult is how a locale file compares to its default locale
+type LocaleResult struct {
+	Key     string   `json:"file"`
+	Default string   `json:"default"`
+	Missing []string `json:"missing"`
+	Extra   []string `json:"extra"`
+}
+
+// Translation is a place where a theme file uses a translation key
+type Translation struct {
+	Key  string `json:"file"`
+	Line int    `json:"line"`
+	Name string `json:"key"`
+}
+
+// Problems will return everything in the report as problems
+func (r LocaleReport) Problems() []Problem {
+	problems := []Problem{}
+	for _, locale := range r.Locales {
+		for _, name := range locale.Missing {
+			problems = append(problems, Problem{Key: locale.Key, Message: fmt.Sprintf("missing %s, which is in %s", name, locale.Default)})
+		}
+		for _, name := range locale.Extra {
+			problems = append(problems, Problem{Key: locale.Key, Message: fmt.Sprintf("%s is not in %s", name, locale.Default)})
+		}
+	}
+	for _, translation := range r.Undefined {
+		problems = append(problems, Problem{Key: translation.Key, Line: translation.Line, Message: fmt.Sprintf("%s is not in the default locale", translation.Name)})
+	}
+	for _, name := range r.Unused {
+		problems = append(problems, Problem{Key: r.Default, Message: fmt.Sprintf("%s is not used by any theme file", name)})
+	}
+	for _, name := range r.UnusedSchema {
+		problems = append(problems, Problem{Key: r.DefaultSchema, Message: fmt.Sprintf("%s is not used by any schema", name)})
+	}
+	return problems
+}
+
+// Locales will compare every locale file, and schema locale file, in the theme
+// files with the keys to its default locale, and find the translations that the
+// liquid files and schemas use but are not in the default locales, or are in the
+// default locales but not used. Keys that are only used by building them in liquid
+// cannot be found, so they are reported as unused.
+func Locales(keys []string, read func(key string) ([]byte, error)) (LocaleReport, error) {
+	report := LocaleReport{Locales: []LocaleResult{}, Unused: []string{}, UnusedSchema: []string{}, Undefined: []Translation{}}
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	locales := map[string]map[string]bool{}
+	used, schemaUsed := map[string]bool{}, map[string]bool{}
+	usages := []Translation{}
+	for _, key := range sorted {
+		isLocale := strings.HasPrefix(key, "locales/") && path.Ext(key) == ".json"
+		if !isLocale && path.Ext(key) != ".liquid" && key != settingsSchemaKey {
+			continue
+		}
+		contents, err := read(key)
+		if err != nil {
+			return report, err
+		}
+		switch {
+		case isLocale:
+			var translations map[string]interface{}
+			if err := parseJSON(contents, &translations); err != nil {
+				return report, fmt.Errorf("%s is not valid json: %s", key, err)
+			}
+			locales[key] = map[string]bool{}
+			flatten("", translations, locales[key])
+			if strings.HasSuffix(key, ".default.schema.json") {
+				report.DefaultSchema = key
+			} else if strings.HasSuffix(key, ".default.json") {
+				report.Default = key
+			}
+		default:
+			for _, match := range translatePattern.FindAllSubmatchIndex(contents, -1) {
+				name := string(contents[match[2]:match[3]])
+				used[name] = true
+				usages = append(usages, Translation{Key: key, Line: bytes.Count(contents[:match[2]], []byte("\n")) + 1, Name: name})
+			}
+			for _, match := range schemaTranslationPattern.FindAllSubmatch(contents, -1) {
+				schemaUsed[string(match[1])] = true
+			}
+		}
+	}
+	if report.Default == "" {
+		return report, fmt.Errorf("there is no default locale, like locales/en.default.json")
+	}
+
+	for _, key := range sorted {
+		defaultKey := report.Default
+		if strings.HasSuffix(key, ".schema.json") {
+			defaultKey = report.DefaultSchema
+		}
+		if locales[key] == nil || key == defaultKey || defaultKey == "" {
+			continue
+		}
+		report.Locales = append(report.Locales, LocaleResult{
+			Key:     key,
+			Default: defaultKey,
+			Missing: difference(locales[defaultKey], locales[key]),
+			Extra:   difference(locales[key], locales[defaultKey]),
+		})
+	}
+
+	for _, name := range sortedNames(locales[report.Default]) {
+		if !isUsed(name, used) {
+			report.Unused = append(report.Unused, name)
+		}
+	}
+	for _, name := range sortedNames(locales[report.DefaultSchema]) {
+		if !isUsed(name, schemaUsed) {
+			report.UnusedSchema = append(report.UnusedSchema, name)
+		}
+	}
+	for _, usage := range usages {
+		if !locales[report.Default][usage.Name] && !isParent(usage.Name, locales[report.Default]) && !strings.HasPrefix(usage.Name, "shopify.") {
+			report.Undefined = append(report.Undefined, usage)
+		}
+	}
+	return report, nil
+}
+
+// flatten will add the dot separated names of all of the translations to names
+func flatten(prefix string, translations map[string]interface{}, names map[string]bool) {
+	for name, value := range translations {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(prefix+name+".", nested, names)
+		} else {
+			names[prefix+name] = true
+		}
+	}
+}
+
+// isUsed will return true if the translation, or the translation it is part of,
+// is used. A pluralized translation like cart.items.one is used by cart.items.
+func isUsed(name string, used map[string]bool) bool {
+	for name != "" {
+		if used[name] {
+			return true
+		}
+		dot := strings.LastIndex(name, ".")
+		if dot < 0 {
+			break
+		}
+		name = name[:dot]
+	}
+	return false
+}
+
+// isParent will return true if the name is the parent of translations, like a
+// pluralized translation
+func isParent(name string, names map[string]bool) bool {
+	for other := range names {
+		if strings.HasPrefix(other, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func difference(a, b map[string]bool) []string {
+	names := []string{}
+	for _, name := range sortedNames(a) {
+		if !b[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func sortedNames(names map[string]bool) []string {
+	sorted := []string{}
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
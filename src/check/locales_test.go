@@ -0,0 +1,68 @@
+package check
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var localesTheme = map[string]string{
+	"locales/en.default.json":        `{"general": {"title": "Title", "unused": "Unused"}, "cart": {"items": {"one": "1 item", "other": "{{ count }} items"}}}`,
+	"locales/fr.json":                `/* generated */ {"general": {"title": "Titre", "old": "Vieux"}}`,
+	"locales/en.default.schema.json": `{"sections": {"header": {"name": "Header"}, "footer": {"name": "Footer"}}}`,
+	"locales/fr.schema.json":         `{"sections": {"header": {"name": "En-tête"}}}`,
+	"layout/theme.liquid":            "{{ 'general.title' | t }}\n{{ \"cart.items\" | t: count: 2 }}\n{{ 'general.missing' | translate }}\n{{ 'shopify.checkout.title' | t }}{{ 'general.title' | truncate: 2 }}",
+	"sections/header.liquid":         `{% schema %}{"name": "t:sections.header.name"}{% endschema %}`,
+	"assets/app.js":                  `'general.unused' | t`,
+}
+
+func TestLocales(t *testing.T) {
+	keys := []string{}
+	for key := range localesTheme {
+		keys = append(keys, key)
+	}
+	report, err := Locales(keys, func(key string) ([]byte, error) {
+		assert.NotEqual(t, "assets/app.js", key)
+		return []byte(localesTheme[key]), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, LocaleReport{
+		Default:       "locales/en.default.json",
+		DefaultSchema: "locales/en.default.schema.json",
+		Locales: []LocaleResult{
+			{Key: "locales/fr.json", Default: "locales/en.default.json", Missing: []string{"cart.items.one", "cart.items.other", "general.unused"}, Extra: []string{"general.old"}},
+			{Key: "locales/fr.schema.json", Default: "locales/en.default.schema.json", Missing: []string{"sections.footer.name"}, Extra: []string{}},
+		},
+		Unused:       []string{"general.unused"},
+		UnusedSchema: []string{"sections.footer.name"},
+		Undefined:    []Translation{{Key: "layout/theme.liquid", Line: 3, Name: "general.missing"}},
+	}, report)
+
+	found := []string{}
+	for _, problem := range report.Problems() {
+		found = append(found, problem.String())
+	}
+	assert.Equal(t, []string{
+		"locales/fr.json: missing cart.items.one, which is in locales/en.default.json",
+		"locales/fr.json: missing cart.items.other, which is in locales/en.default.json",
+		"locales/fr.json: missing general.unused, which is in locales/en.default.json",
+		"locales/fr.json: general.old is not in locales/en.default.json",
+		"locales/fr.schema.json: missing sections.footer.name, which is in locales/en.default.schema.json",
+		"layout/theme.liquid:3: general.missing is not in the default locale",
+		"locales/en.default.json: general.unused is not used by any theme file",
+		"locales/en.default.schema.json: sections.footer.name is not used by any schema",
+	}, found)
+}
+
+func TestLocalesErrors(t *testing.T) {
+	_, err := Locales([]string{"locales/fr.json"}, func(string) ([]byte, error) { return []byte(`{}`), nil })
+	assert.EqualError(t, err, "there is no default locale, like locales/en.default.json")
+
+	_, err = Locales([]string{"locales/en.default.json"}, func(string) ([]byte, error) { return []byte(`{`), nil })
+	assert.Contains(t, err.Error(), "locales/en.default.json is not valid json")
+
+	readErr := errors.New("cannot read")
+	_, err = Locales([]string{"layout/theme.liquid"}, func(string) ([]byte, error) { return nil, readErr })
+	assert.Equal(t, readErr, err)
+}
@@ -0,0 +1,377 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	settingsSchemaKey = "config/settings_schema.json"
+	settingsDataKey   = "config/settings_data.json"
+)
+
+var schemaPattern = regexp.MustCompile(`(?s)\{%-?\s*schema\s*-?%\}(.*?)\{%-?\s*endschema\s*-?%\}`)
+
+// settingTypes are the json types of the values of each type of setting. Settings
+// of a type that is not listed are not checked.
+var settingTypes = map[string]string{
+	"article":          "string",
+	"blog":             "string",
+	"checkbox":         "boolean",
+	"collection":       "string",
+	"collection_list":  "array",
+	"color":            "string",
+	"color_background": "string",
+	"color_scheme":     "string",
+	"font_picker":      "string",
+	"html":             "string",
+	"image_picker":     "string",
+	"inline_richtext":  "string",
+	"link_list":        "string",
+	"liquid":           "string",
+	"number":           "number",
+	"page":             "string",
+	"product":          "string",
+	"product_list":     "array",
+	"radio":            "string",
+	"range":            "number",
+	"richtext":         "string",
+	"select":           "string",
+	"text":             "string",
+	"text_alignment":   "string",
+	"textarea":         "string",
+	"url":              "string",
+	"video":            "string",
+	"video_url":        "string",
+}
+
+type settingSchema struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Min     *float64 `json:"min"`
+	Max     *float64 `json:"max"`
+	Options []struct {
+		Value interface{} `json:"value"`
+	} `json:"options"`
+}
+
+type blockSchema struct {
+	Type     string          `json:"type"`
+	Settings []settingSchema `json:"settings"`
+}
+
+type sectionSchema struct {
+	Settings  []settingSchema `json:"settings"`
+	Blocks    []blockSchema   `json:"blocks"`
+	MaxBlocks int             `json:"max_blocks"`
+}
+
+type sectionData struct {
+	Type       string                 `json:"type"`
+	Settings   map[string]interface{} `json:"settings"`
+	Blocks     map[string]blockData   `json:"blocks"`
+	BlockOrder []string               `json:"block_order"`
+}
+
+type blockData struct {
+	Type     string                 `json:"type"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+type templateData struct {
+	Sections map[string]sectionData `json:"sections"`
+	Order    []string               `json:"order"`
+}
+
+// settingsChecker checks settings data against the schemas of the theme settings
+// and sections
+type settingsChecker struct {
+	theme    []settingSchema
+	sections map[string]*sectionSchema
+	problems []Problem
+}
+
+// Settings will check config/settings_data.json, the json templates and the
+// section groups of the theme files with the keys against config/settings_schema.json
+// and the schemas of the sections. It reports unknown settings, values of the wrong
+// type or out of range, and missing blocks and sections. Files that are not valid
+// json are left for File to report.
+func Settings(keys []string, read func(key string) ([]byte, error)) ([]Problem, error) {
+	c := &settingsChecker{sections: map[string]*sectionSchema{}, problems: []Problem{}}
+	dataKeys := []string{}
+	for _, key := range keys {
+		switch {
+		case key == settingsSchemaKey:
+			contents, err := read(key)
+			if err != nil {
+				return nil, err
+			}
+			var groups []struct {
+				Settings []settingSchema `json:"settings"`
+			}
+			if parseJSON(contents, &groups) == nil {
+				for _, group := range groups {
+					c.theme = append(c.theme, group.Settings...)
+				}
+			}
+		case strings.HasPrefix(key, "sections/") && path.Ext(key) == ".liquid":
+			contents, err := read(key)
+			if err != nil {
+				return nil, err
+			}
+			// a section with a schema that is not valid json is not checked
+			schema := &sectionSchema{}
+			if match := schemaPattern.FindSubmatch(contents); match != nil && parseJSON(match[1], schema) != nil {
+				schema = nil
+			}
+			c.sections[strings.TrimSuffix(path.Base(key), ".liquid")] = schema
+		case key == settingsDataKey, isTemplateData(key):
+			dataKeys = append(dataKeys, key)
+		}
+	}
+
+	sort.Strings(dataKeys)
+	for _, key := range dataKeys {
+		contents, err := read(key)
+		if err != nil {
+			return nil, err
+		}
+		if key == settingsDataKey {
+			c.checkSettingsData(key, contents)
+		} else {
+			var data templateData
+			if parseJSON(contents, &data) == nil {
+				c.checkOrder(key, data.Sections, data.Order)
+				c.checkSections(key, "sections", data.Sections)
+			}
+		}
+	}
+	return c.problems, nil
+}
+
+func (c *settingsChecker) checkSettingsData(key string, contents []byte) {
+	var data struct {
+		Current json.RawMessage            `json:"current"`
+		Presets map[string]json.RawMessage `json:"presets"`
+	}
+	if parseJSON(contents, &data) != nil {
+		return
+	}
+
+	values := map[string]json.RawMessage{"current": data.Current}
+	for name, preset := range data.Presets {
+		values["presets."+name] = preset
+	}
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var settings map[string]interface{}
+		// current can be the name of a preset instead of settings
+		if json.Unmarshal(values[name], &settings) != nil {
+			continue
+		}
+		var sections map[string]sectionData
+		if raw, ok := settings["sections"]; ok {
+			encoded, _ := json.Marshal(raw)
+			json.Unmarshal(encoded, &sections)
+			delete(settings, "sections")
+		}
+		delete(settings, "content_for_index")
+		delete(settings, "blocks")
+		// checkout settings are kept with the theme settings but are not in its schema
+		for id := range settings {
+			if strings.HasPrefix(id, "checkout_") {
+				delete(settings, id)
+			}
+		}
+		c.checkSettings(key, name, c.theme, settings, settingsSchemaKey)
+		c.checkSections(key, name+".sections", sections)
+	}
+}
+
+func (c *settingsChecker) checkOrder(key string, sections map[string]sectionData, order []string) {
+	for _, id := range order {
+		if _, ok := sections[id]; !ok {
+			c.problem(key, "order lists the section %s, which is missing", id)
+		}
+	}
+}
+
+func (c *settingsChecker) checkSections(key, at string, sections map[string]sectionData) {
+	for _, id := range sortedKeys(sections) {
+		section := sections[id]
+		sectionAt := at + "." + id
+		schema, ok := c.sections[section.Type]
+		if !ok {
+			c.problem(key, "%s has the type %s but sections/%s.liquid does not exist", sectionAt, section.Type, section.Type)
+			continue
+		} else if schema == nil {
+			continue
+		}
+		owner := fmt.Sprintf("sections/%s.liquid", section.Type)
+		c.checkSettings(key, sectionAt+".settings", schema.Settings, section.Settings, owner)
+
+		for _, blockID := range section.BlockOrder {
+			if _, ok := section.Blocks[blockID]; !ok {
+				c.problem(key, "%s.block_order lists the block %s, which is missing", sectionAt, blockID)
+			}
+		}
+		if schema.MaxBlocks > 0 && len(section.Blocks) > schema.MaxBlocks {
+			c.problem(key, "%s has %d blocks, more than the max_blocks of %d in %s", sectionAt, len(section.Blocks), schema.MaxBlocks, owner)
+		}
+		for _, blockID := range sortedKeys(section.Blocks) {
+			block := section.Blocks[blockID]
+			blockAt := sectionAt + ".blocks." + blockID
+			blockSchema, ok := schema.block(block.Type)
+			if !ok && schema.allowsBlock(block.Type) {
+				continue
+			} else if !ok {
+				c.problem(key, "%s has the type %s, which is not a block of %s", blockAt, block.Type, owner)
+				continue
+			}
+			c.checkSettings(key, blockAt+".settings", blockSchema.Settings, block.Settings, fmt.Sprintf("the %s block of %s", block.Type, owner))
+		}
+	}
+}
+
+func (c *settingsChecker) checkSettings(key, at string, schema []settingSchema, settings map[string]interface{}, owner string) {
+	byID := map[string]settingSchema{}
+	for _, setting := range schema {
+		byID[setting.ID] = setting
+	}
+	for _, id := range sortedKeys(settings) {
+		setting, ok := byID[id]
+		if !ok {
+			c.problem(key, "%s.%s is not a setting of %s", at, id, owner)
+		} else if problem := checkValue(setting, settings[id]); problem != "" {
+			c.problem(key, "%s.%s %s", at, id, problem)
+		}
+	}
+}
+
+// checkValue will return what is wrong with the value of a setting, or nothing if
+// it is valid. Empty values and values that are liquid, which can be used to
+// connect a setting to a dynamic source, are always valid.
+func checkValue(setting settingSchema, value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if text, ok := value.(string); ok && strings.Contains(text, "{{") {
+		return ""
+	}
+
+	expected, known := settingTypes[setting.Type]
+	if !known {
+		return ""
+	} else if actual := jsonType(value); actual != expected {
+		return fmt.Sprintf("is %s, but %s settings have to be %s", withArticle(actual), setting.Type, withArticle(expected))
+	}
+
+	if number, ok := value.(float64); ok {
+		if setting.Min != nil && number < *setting.Min {
+			return fmt.Sprintf("is %v, less than the min of %v", number, *setting.Min)
+		} else if setting.Max != nil && number > *setting.Max {
+			return fmt.Sprintf("is %v, more than the max of %v", number, *setting.Max)
+		}
+	}
+
+	if len(setting.Options) > 0 && (setting.Type == "select" || setting.Type == "radio") {
+		options := []string{}
+		for _, option := range setting.Options {
+			if option.Value == value {
+				return ""
+			}
+			options = append(options, fmt.Sprintf("%v", option.Value))
+		}
+		return fmt.Sprintf("is %q, which is not one of the options %s", value, strings.Join(options, ", "))
+	}
+	return ""
+}
+
+func (s sectionSchema) block(blockType string) (blockSchema, bool) {
+	for _, block := range s.Blocks {
+		if block.Type == blockType {
+			return block, true
+		}
+	}
+	return blockSchema{}, false
+}
+
+// allowsBlock will return true if the section accepts blocks of the type that are
+// defined outside of it, by an app or in the blocks directory, so their settings
+// cannot be checked
+func (s sectionSchema) allowsBlock(blockType string) bool {
+	for _, block := range s.Blocks {
+		if (block.Type == "@app" && strings.HasPrefix(blockType, "shopify://")) || block.Type == "@theme" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *settingsChecker) problem(key, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// isTemplateData will return true for the json templates and section groups
+func isTemplateData(key string) bool {
+	return path.Ext(key) == ".json" && (strings.HasPrefix(key, "templates/") || strings.HasPrefix(key, "sections/"))
+}
+
+// parseJSON will parse json that may start with a comment, the way shopify writes
+// its json files
+func parseJSON(contents []byte, v interface{}) error {
+	if loc := commentPattern.FindIndex(contents); loc != nil {
+		contents = contents[loc[1]:]
+	}
+	return json.Unmarshal(contents, v)
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func withArticle(jsonType string) string {
+	if strings.ContainsAny(jsonType[:1], "aeiou") {
+		return "an " + jsonType
+	}
+	return "a " + jsonType
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch m := m.(type) {
+	case map[string]sectionData:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]blockData:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]interface{}:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
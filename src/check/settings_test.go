@@ -0,0 +1,123 @@
+package check
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var settingsTheme = map[string]string{
+	"config/settings_schema.json": `[
+		{"name": "theme_info", "theme_name": "Test"},
+		{"name": "Colors", "settings": [
+			{"type": "color", "id": "color_text"},
+			{"type": "checkbox", "id": "show_cart"},
+			{"type": "range", "id": "page_width", "min": 1000, "max": 1600, "step": 100}
+		]}
+	]`,
+	"config/settings_data.json": `/* generated */ {
+		"current": {
+			"color_text": "#000",
+			"show_cart": "yes",
+			"page_width": 2000,
+			"old_setting": 1,
+			"checkout_logo_image": "logo.png",
+			"sections": {"header": {"type": "header", "settings": {"sticky": true}}},
+			"content_for_index": []
+		},
+		"presets": {"Default": {"color_text": 1}}
+	}`,
+	"sections/header.liquid": `{% schema %}{"name": "Header", "settings": [{"type": "checkbox", "id": "sticky"}]}{% endschema %}`,
+	"sections/main.liquid": `{% schema %}
+		{
+			"name": "Main",
+			"max_blocks": 2,
+			"settings": [
+				{"type": "select", "id": "layout", "options": [{"value": "full", "label": "Full"}, {"value": "half", "label": "Half"}]},
+				{"type": "text", "id": "heading"}
+			],
+			"blocks": [
+				{"type": "text", "name": "Text", "settings": [{"type": "number", "id": "size", "min": 1}]},
+				{"type": "@app"}
+			]
+		}
+	{% endschema %}`,
+	"sections/broken.liquid": `{% schema %}{{% endschema %}`,
+	"templates/index.json": `{
+		"sections": {
+			"main": {
+				"type": "main",
+				"settings": {"layout": "wide", "heading": "{{ product.title }}", "unknown": true},
+				"blocks": {
+					"a": {"type": "text", "settings": {"size": 0}},
+					"b": {"type": "image", "settings": {}},
+					"c": {"type": "shopify://apps/reviews/blocks/stars/1", "settings": {"any": 1}}
+				},
+				"block_order": ["a", "b", "c", "d"]
+			},
+			"broken": {"type": "broken", "settings": {"anything": 1}},
+			"gone": {"type": "gone"}
+		},
+		"order": ["main", "broken", "gone", "missing"]
+	}`,
+	"templates/product.json": `{`,
+	"assets/app.js":          `var a;`,
+}
+
+func TestSettings(t *testing.T) {
+	keys := []string{}
+	for key := range settingsTheme {
+		keys = append(keys, key)
+	}
+	problems, err := Settings(keys, func(key string) ([]byte, error) {
+		assert.NotEqual(t, "assets/app.js", key)
+		return []byte(settingsTheme[key]), nil
+	})
+	assert.Nil(t, err)
+
+	found := []string{}
+	for _, problem := range problems {
+		found = append(found, problem.String())
+	}
+	assert.Equal(t, []string{
+		`config/settings_data.json: current.old_setting is not a setting of config/settings_schema.json`,
+		`config/settings_data.json: current.page_width is 2000, more than the max of 1600`,
+		`config/settings_data.json: current.show_cart is a string, but checkbox settings have to be a boolean`,
+		`config/settings_data.json: presets.Default.color_text is a number, but color settings have to be a string`,
+		`templates/index.json: order lists the section missing, which is missing`,
+		`templates/index.json: sections.gone has the type gone but sections/gone.liquid does not exist`,
+		`templates/index.json: sections.main.settings.layout is "wide", which is not one of the options full, half`,
+		`templates/index.json: sections.main.settings.unknown is not a setting of sections/main.liquid`,
+		`templates/index.json: sections.main.block_order lists the block d, which is missing`,
+		`templates/index.json: sections.main has 3 blocks, more than the max_blocks of 2 in sections/main.liquid`,
+		`templates/index.json: sections.main.blocks.a.settings.size is 0, less than the min of 1`,
+		`templates/index.json: sections.main.blocks.b has the type image, which is not a block of sections/main.liquid`,
+	}, found)
+
+	readErr := errors.New("cannot read")
+	_, err = Settings([]string{"config/settings_data.json"}, func(string) ([]byte, error) { return nil, readErr })
+	assert.Equal(t, readErr, err)
+}
+
+func TestCheckValue(t *testing.T) {
+	min, max := 1.0, 10.0
+	testcases := []struct {
+		setting settingSchema
+		value   interface{}
+		problem string
+	}{
+		{setting: settingSchema{Type: "text"}, value: "hello"},
+		{setting: settingSchema{Type: "text"}, value: nil},
+		{setting: settingSchema{Type: "checkbox"}, value: "{{ product.available }}"},
+		{setting: settingSchema{Type: "unknown_type"}, value: 1},
+		{setting: settingSchema{Type: "product_list"}, value: []interface{}{"a"}},
+		{setting: settingSchema{Type: "product_list"}, value: "a", problem: "is a string, but product_list settings have to be an array"},
+		{setting: settingSchema{Type: "range", Min: &min, Max: &max}, value: 5.0},
+		{setting: settingSchema{Type: "range", Min: &min, Max: &max}, value: 11.0, problem: "is 11, more than the max of 10"},
+		{setting: settingSchema{Type: "number"}, value: map[string]interface{}{}, problem: "is an object, but number settings have to be a number"},
+	}
+	for _, testcase := range testcases {
+		assert.Equal(t, testcase.problem, checkValue(testcase.setting, testcase.value), testcase.setting.Type)
+	}
+}
@@ -0,0 +1,50 @@
+package cmdutil
+
+import (
+	"sync"
+
+	"github.com/Shopify/themekit/src/file"
+)
+
+// DefaultConcurrency is how many file operations are run at the same time for an
+// environment that has no concurrency configured. Requests to shopify are rate
+// limited, so more workers mostly just hold more files in memory while waiting.
+const DefaultConcurrency = 10
+
+// Task is a single file operation to be run by Run
+type Task struct {
+	Path string
+	Op   file.Op
+}
+
+// Run will call work for every task, with at most the configured concurrency of
+// the environment running at a time, and returns once all of the tasks are done.
+// work returns the file operation that was carried out, which is then marked as
+// done on the context. Any files should be read by work itself so that only the
+// files currently being worked on are held in memory.
+func (ctx *Ctx) Run(tasks []Task, work func(Task) file.Op) {
+	queue := make(chan Task)
+	var workers sync.WaitGroup
+	for i := 0; i < ctx.concurrency() && i < len(tasks); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for task := range queue {
+				ctx.DoneTask(work(task))
+			}
+		}()
+	}
+
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+	workers.Wait()
+}
+
+func (ctx *Ctx) concurrency() int {
+	if ctx.Env != nil && ctx.Env.Concurrency > 0 {
+		return ctx.Env.Concurrency
+	}
+	return DefaultConcurrency
+}
@@ -0,0 +1,50 @@
+package cmdutil
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/file"
+)
+
+func TestCtx_Run(t *testing.T) {
+	testcases := []struct {
+		concurrency, tasks, expectedMax int
+	}{
+		{concurrency: 0, tasks: 25, expectedMax: DefaultConcurrency},
+		{concurrency: 3, tasks: 25, expectedMax: 3},
+		{concurrency: 5, tasks: 2, expectedMax: 2},
+		{concurrency: 1, tasks: 0, expectedMax: 0},
+	}
+
+	for _, testcase := range testcases {
+		ctx := Ctx{Env: &env.Env{Concurrency: testcase.concurrency}}
+		tasks := []Task{}
+		for i := 0; i < testcase.tasks; i++ {
+			tasks = append(tasks, Task{Path: "assets/app.js", Op: file.Update})
+		}
+
+		var running, max int32
+		var mu sync.Mutex
+		ctx.Run(tasks, func(task Task) file.Op {
+			current := atomic.AddInt32(&running, 1)
+			mu.Lock()
+			if current > max {
+				max = current
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return file.Skip
+		})
+
+		assert.Equal(t, int32(testcase.expectedMax), max)
+		assert.Equal(t, int32(testcase.tasks), ctx.summary.skipped)
+		assert.Equal(t, int32(0), ctx.summary.uploaded)
+	}
+}
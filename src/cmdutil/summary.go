@@ -7,12 +7,16 @@ import (
 
 	"github.com/Shopify/themekit/src/colors"
 	"github.com/Shopify/themekit/src/file"
+	"github.com/Shopify/themekit/src/state"
 )
 
 type cmdSummary struct {
-	actions, downloaded, uploaded, skipped, removed int32
-	disabled                                        bool
-	errors                                          []string
+	actions, downloaded, uploaded, skipped, removed, conflicted, merged, pruned int32
+	disabled                                                                    bool
+	errors                                                                      []string
+	conflicts                                                                   []string
+	keptBoth                                                                    []string
+	failures                                                                    []state.Failure
 }
 
 func (sum *cmdSummary) completeOp(op file.Op) {
@@ -26,6 +30,12 @@ func (sum *cmdSummary) completeOp(op file.Op) {
 		atomic.AddInt32(&sum.removed, 1)
 	case file.Get:
 		atomic.AddInt32(&sum.downloaded, 1)
+	case file.Conflict:
+		atomic.AddInt32(&sum.conflicted, 1)
+	case file.Merge:
+		atomic.AddInt32(&sum.merged, 1)
+	case file.Prune:
+		atomic.AddInt32(&sum.pruned, 1)
 	}
 }
 
@@ -37,10 +47,39 @@ func (sum *cmdSummary) err(errStr string) {
 	sum.errors = append(sum.errors, errStr)
 }
 
+func (sum *cmdSummary) conflict(path string) {
+	sum.conflicts = append(sum.conflicts, path)
+}
+
+func (sum *cmdSummary) keepBoth(path string) {
+	sum.keptBoth = append(sum.keptBoth, path)
+}
+
+func (sum *cmdSummary) fail(path, cause string) {
+	sum.failures = append(sum.failures, state.Failure{Path: path, Cause: cause})
+}
+
 func (sum *cmdSummary) hasErrors() bool {
 	return !sum.disabled && len(sum.errors) > 0
 }
 
+// hasConflicts will return true if files were left as they were because they
+// changed on shopify, so that the command does not look like it succeeded
+func (sum *cmdSummary) hasConflicts() bool {
+	return !sum.disabled && len(sum.conflicts) > 0
+}
+
+// runtimeErr will return the error a command should finish with when it did not
+// fail itself, which is nil if no file operations errored or conflicted
+func (sum *cmdSummary) runtimeErr() error {
+	if sum.hasErrors() {
+		return ErrDuringRuntime
+	} else if sum.hasConflicts() {
+		return ErrConflicts
+	}
+	return nil
+}
+
 func (sum *cmdSummary) display(ctx *Ctx) {
 	if sum.disabled || sum.actions == 0 {
 		return
@@ -55,13 +94,34 @@ func (sum *cmdSummary) display(ctx *Ctx) {
 	if sum.removed > 0 {
 		results = append(results, fmt.Sprintf("%v: %v", colors.Yellow("Removed"), sum.removed))
 	}
+	if sum.pruned > 0 {
+		results = append(results, fmt.Sprintf("%v: %v", colors.Yellow("Pruned"), sum.pruned))
+	}
 	if sum.skipped > 0 {
 		results = append(results, fmt.Sprintf("%v: %v", colors.Cyan("No Change"), sum.skipped))
 	}
+	if sum.merged > 0 {
+		results = append(results, fmt.Sprintf("%v: %v", colors.Green("Merged"), sum.merged))
+	}
+	if sum.conflicted > 0 {
+		results = append(results, fmt.Sprintf("%v: %v", colors.Yellow("Conflicts"), sum.conflicted))
+	}
 	if len(sum.errors) > 0 {
 		results = append(results, fmt.Sprintf("%v: %v", colors.Red("Errored"), len(sum.errors)))
 	}
 	ctx.Log.Printf("[%v] %v", colors.Green(ctx.Env.Name), strings.Join(results, ", "))
+	if len(sum.conflicts) > 0 {
+		ctx.Log.Printf("[%s] %s", colors.Green(ctx.Env.Name), colors.Yellow("Changed on shopify since the last sync: "))
+		for _, path := range sum.conflicts {
+			ctx.Log.Printf("\t%v", colors.Blue(path))
+		}
+	}
+	if len(sum.keptBoth) > 0 {
+		ctx.Log.Printf("[%s] %s", colors.Green(ctx.Env.Name), colors.Yellow("Changed both locally and on shopify, the version on shopify was written next to: "))
+		for _, path := range sum.keptBoth {
+			ctx.Log.Printf("\t%v", colors.Blue(path))
+		}
+	}
 	if len(sum.errors) > 0 {
 		ctx.ErrLog.Printf("[%s] %s", colors.Green(ctx.Env.Name), colors.Red("Errors encountered: "))
 		for _, msg := range sum.errors {
@@ -27,7 +27,16 @@ func TestSummaryCompleteOp(t *testing.T) {
 	summary.completeOp(file.Remove)
 	assert.Equal(t, summary.removed, int32(1))
 
-	assert.Equal(t, summary.actions, int32(4))
+	summary.completeOp(file.Conflict)
+	assert.Equal(t, summary.conflicted, int32(1))
+
+	summary.completeOp(file.Merge)
+	assert.Equal(t, summary.merged, int32(1))
+
+	summary.completeOp(file.Prune)
+	assert.Equal(t, summary.pruned, int32(1))
+
+	assert.Equal(t, summary.actions, int32(7))
 }
 
 func TestSummaryDisable(t *testing.T) {
@@ -44,6 +53,13 @@ func TestSummaryErr(t *testing.T) {
 	assert.Equal(t, summary.errors, []string{"no good"})
 }
 
+func TestSummaryConflict(t *testing.T) {
+	summary := cmdSummary{}
+	summary.conflict("assets/app.js")
+	assert.Equal(t, summary.conflicts, []string{"assets/app.js"})
+	assert.False(t, summary.hasErrors())
+}
+
 func TestSummaryHasErrors(t *testing.T) {
 	summary := cmdSummary{}
 	assert.False(t, summary.hasErrors())
@@ -53,6 +69,21 @@ func TestSummaryHasErrors(t *testing.T) {
 	assert.False(t, summary.hasErrors())
 }
 
+func TestSummaryRuntimeErr(t *testing.T) {
+	summary := cmdSummary{}
+	assert.Nil(t, summary.runtimeErr())
+	summary.keepBoth("assets/theme.js")
+	assert.Nil(t, summary.runtimeErr())
+	summary.conflict("assets/app.js")
+	assert.True(t, summary.hasConflicts())
+	assert.Equal(t, ErrConflicts, summary.runtimeErr())
+	summary.err("no good")
+	assert.Equal(t, ErrDuringRuntime, summary.runtimeErr())
+	summary.disable()
+	assert.False(t, summary.hasConflicts())
+	assert.Nil(t, summary.runtimeErr())
+}
+
 func TestSummaryDisplay(t *testing.T) {
 	out, err := rundisplay(cmdSummary{actions: 23})
 	assert.Equal(t, out, fmt.Sprintf("[sum] 23 files\n"))
@@ -70,10 +101,18 @@ func TestSummaryDisplay(t *testing.T) {
 	assert.Equal(t, out, fmt.Sprintf("[sum] 23 files, Removed: 42\n"))
 	assert.Equal(t, err, "")
 
+	out, err = rundisplay(cmdSummary{actions: 23, pruned: 4})
+	assert.Equal(t, out, fmt.Sprintf("[sum] 23 files, Pruned: 4\n"))
+	assert.Equal(t, err, "")
+
 	out, err = rundisplay(cmdSummary{actions: 23, skipped: 11})
 	assert.Equal(t, out, fmt.Sprintf("[sum] 23 files, No Change: 11\n"))
 	assert.Equal(t, err, "")
 
+	out, err = rundisplay(cmdSummary{actions: 2, conflicted: 2, conflicts: []string{"assets/app.js"}, keptBoth: []string{"assets/theme.js"}})
+	assert.Equal(t, out, fmt.Sprintf("[sum] 2 files, Conflicts: 2\n[sum] Changed on shopify since the last sync: \n\tassets/app.js\n[sum] Changed both locally and on shopify, the version on shopify was written next to: \n\tassets/theme.js\n"))
+	assert.Equal(t, err, "")
+
 	out, err = rundisplay(cmdSummary{actions: 23, errors: []string{"one", "two", "three"}})
 	assert.Equal(t, out, fmt.Sprintf("[sum] 23 files, Errored: 3\n"))
 	assert.Equal(t, err, "[sum] Errors encountered: \n\tone\n\ttwo\n\tthree\n")
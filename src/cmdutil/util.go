@@ -1,10 +1,13 @@
 package cmdutil
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +21,7 @@ import (
 	"github.com/Shopify/themekit/src/env"
 	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
 )
 
 // ErrReload is an error to return from a command if you want to reload and run again
@@ -25,6 +29,7 @@ var (
 	ErrReload        = errors.New("reloading config")
 	ErrLiveTheme     = errors.New("cannot make changes to a live theme without an override")
 	ErrDuringRuntime = errors.New("finished command with errors")
+	ErrConflicts     = errors.New("finished command with conflicts that were not resolved")
 )
 
 // Flags encapsulates all the possible flags that can be set in the themekit
@@ -58,16 +63,40 @@ type Flags struct {
 	Live                          bool
 	HidePreviewBar                bool
 	DisableThemeKitAccessNotifier bool
+	OnConflict                    string
+	MergeSettings                 bool
+	Concurrency                   int
+	Changed                       bool
+	Since                         string
+	ForceDelete                   bool
+	StealLock                     bool
+	Resume                        bool
+	RetryFailed                   bool
+	Prune                         bool
+	PruneUnsynced                 bool
+	DryRun                        bool
+	Archive                       string
+	From                          string
+	Template                      string
+	Locale                        string
+	OnExists                      string
+	ValidateSettings              bool
+	SettingsOnly                  bool
+	JSON                          bool
 }
 
 // Ctx is a specific context that a command will run in
 type Ctx struct {
-	Shop     shopify.Shop
-	Conf     config
-	Client   shopifyClient
-	Flags    Flags
-	Env      *env.Env
-	Args     []string
+	Shop   shopify.Shop
+	Conf   config
+	Client shopifyClient
+	Flags  Flags
+	Env    *env.Env
+	State  *state.Sync
+	Args   []string
+	// In is where answers to confirmations are read from, it is nil when not
+	// running in an interactive terminal
+	In       io.Reader
 	Log      *log.Logger
 	ErrLog   *log.Logger
 	progress *mpb.Progress
@@ -134,13 +163,24 @@ func createCtx(newClient clientFact, conf env.Conf, e *env.Env, flags Flags, arg
 		}
 	}
 
+	syncState, err := state.Load(e)
+	if err != nil {
+		colors.ColorStdErr.Printf(
+			"[%s] could not read the last sync state, changes made on shopify will not be detected: %s",
+			colors.Yellow(e.Name),
+			err,
+		)
+	}
+
 	return &Ctx{
 		Shop:     shop,
 		Conf:     &conf,
 		Client:   client,
 		Env:      e,
+		State:    syncState,
 		Flags:    flags,
 		Args:     args,
+		In:       terminalInput(),
 		progress: progress,
 		Log:      colors.ColorStdOut,
 		ErrLog:   colors.ColorStdErr,
@@ -148,6 +188,31 @@ func createCtx(newClient clientFact, conf env.Conf, e *env.Env, flags Flags, arg
 	}, nil
 }
 
+// only one context at a time can ask for confirmation
+var confirmMu sync.Mutex
+
+// Confirm will ask a yes or no question and return true if it was answered with
+// yes. If not running in an interactive terminal then nothing is asked and false
+// is returned.
+func (ctx *Ctx) Confirm(question string) bool {
+	if ctx.In == nil {
+		return false
+	}
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	fmt.Fprintf(ctx.Log.Writer(), "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(ctx.In).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func terminalInput() io.Reader {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return os.Stdin
+	}
+	return nil
+}
+
 // StartProgress will create a new progress bar for the running context with the
 // total amount of tasks as the count
 func (ctx *Ctx) StartProgress(count int) {
@@ -170,6 +235,46 @@ func (ctx *Ctx) Err(msg string, inter ...interface{}) {
 	}
 }
 
+// Conflict will record that a file could not be transferred because it has changed
+// on shopify since it was last synced
+func (ctx *Ctx) Conflict(path string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.summary.conflict(path)
+	if ctx.progress == nil || ctx.Bar == nil {
+		ctx.Log.Printf("[%s] %s %s", colors.Green(ctx.Env.Name), colors.Yellow("Conflict"), colors.Blue(path))
+	}
+}
+
+// KeptBoth will record that a file has changed both locally and on shopify and
+// that the version on shopify was written next to it, so that they can be merged
+// by hand. The file is left for the user on purpose so it is not an error.
+func (ctx *Ctx) KeptBoth(path string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.summary.keepBoth(path)
+	if ctx.progress == nil || ctx.Bar == nil {
+		ctx.Log.Printf("[%s] %s %s", colors.Green(ctx.Env.Name), colors.Yellow("Kept both versions of"), colors.Blue(path))
+	}
+}
+
+// Failed will record that the file operation on a path failed, so that it can be
+// retried later
+func (ctx *Ctx) Failed(path string, err error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.summary.fail(path, err.Error())
+}
+
+// Failures will return the file operations that have failed so far, sorted by path
+func (ctx *Ctx) Failures() []state.Failure {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	failures := append([]state.Failure{}, ctx.summary.failures...)
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Path < failures[j].Path })
+	return failures
+}
+
 // DoneTask will mark one unit of work complete. If the context has a progress bar
 // then it will increment it.
 func (ctx *Ctx) DoneTask(op file.Op) {
@@ -179,6 +284,12 @@ func (ctx *Ctx) DoneTask(op file.Op) {
 	ctx.summary.completeOp(op)
 }
 
+func (ctx *Ctx) saveState() {
+	if err := ctx.State.Save(); err != nil {
+		ctx.ErrLog.Printf("[%s] could not save the sync state: %s", colors.Green(ctx.Env.Name), err)
+	}
+}
+
 // DisableSummary will ensure that the file operation summary will not output at
 // the end of the operation
 func (ctx *Ctx) DisableSummary() {
@@ -227,13 +338,14 @@ func generateContexts(newClient clientFact, progress *mpb.Progress, flags Flags,
 
 func getFlagEnv(flags Flags) env.Env {
 	flagEnv := env.Env{
-		Directory: flags.Directory,
-		Password:  flags.Password,
-		ThemeID:   flags.ThemeID,
-		Domain:    flags.Domain,
-		Proxy:     flags.Proxy,
-		Timeout:   flags.Timeout,
-		Notify:    flags.Notify,
+		Directory:   flags.Directory,
+		Password:    flags.Password,
+		ThemeID:     flags.ThemeID,
+		Domain:      flags.Domain,
+		Proxy:       flags.Proxy,
+		Timeout:     flags.Timeout,
+		Notify:      flags.Notify,
+		Concurrency: flags.Concurrency,
 	}
 
 	if !flags.DisableIgnore {
@@ -293,13 +405,16 @@ func forEachClient(newClient clientFact, flags Flags, args []string, handler fun
 	if err == ErrReload {
 		return forEachClient(newClient, flags, args, handler)
 	}
-	hasErrors := false
+	var runtimeErr error
 	for _, ctx := range ctxs {
+		ctx.saveState()
 		ctx.summary.display(ctx)
-		hasErrors = hasErrors || ctx.summary.hasErrors()
+		if ctxErr := ctx.summary.runtimeErr(); runtimeErr != ErrDuringRuntime && ctxErr != nil {
+			runtimeErr = ctxErr
+		}
 	}
-	if err == nil && hasErrors {
-		return ErrDuringRuntime
+	if err == nil {
+		return runtimeErr
 	}
 	return err
 }
@@ -326,9 +441,10 @@ func forSingleClient(newClient clientFact, flags Flags, args []string, handler f
 	if err == ErrReload {
 		return forSingleClient(newClient, flags, args, handler)
 	}
+	ctxs[0].saveState()
 	ctxs[0].summary.display(ctxs[0])
-	if err == nil && ctxs[0].summary.hasErrors() {
-		return ErrDuringRuntime
+	if err == nil {
+		return ctxs[0].summary.runtimeErr()
 	}
 	return err
 }
@@ -377,15 +493,72 @@ func forDefaultClient(newClient clientFact, flags Flags, args []string, handler
 		progressBarGroup.Wait()
 	}
 
+	ctx.saveState()
 	ctx.summary.display(ctx)
 
-	if err == nil && ctx.summary.hasErrors() {
-		return ErrDuringRuntime
+	if err == nil {
+		return ctx.summary.runtimeErr()
 	}
 
 	return err
 }
 
+// ForLocalEnv will run a command that only works with the project directory in the
+// context of a single environment, without connecting to shopify. The environment
+// only needs a valid directory, it does not need a password, theme id or store.
+func ForLocalEnv(flags Flags, args []string, handler func(*Ctx) error) error {
+	if err := env.SourceVariables(flags.VariableFilePath); err != nil {
+		return err
+	}
+
+	config, err := env.Load(flags.ConfigPath)
+	if err != nil && os.IsNotExist(err) {
+		config = env.New(flags.ConfigPath)
+	} else if err != nil {
+		return err
+	}
+
+	envName := env.Default.Name
+	if len(flags.Environments) > 0 {
+		envName = flags.Environments[0]
+	}
+
+	flagEnv := getFlagEnv(flags)
+	e, err := config.Get(envName, flagEnv)
+	if err == env.ErrEnvDoesNotExist {
+		e, err = config.Set(envName, flagEnv)
+	}
+	if e == nil {
+		return err
+	}
+	if info, err := os.Stat(e.Directory); err != nil {
+		return fmt.Errorf("invalid project directory %v", err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", e.Directory)
+	}
+
+	if flags.DisableIgnore {
+		e.IgnoredFiles = []string{}
+		e.Ignores = []string{}
+	}
+
+	ctx := &Ctx{
+		Conf:    &config,
+		Env:     e,
+		Flags:   flags,
+		Args:    args,
+		Log:     colors.ColorStdOut,
+		ErrLog:  colors.ColorStdErr,
+		summary: cmdSummary{},
+	}
+	err = handler(ctx)
+	ctx.summary.display(ctx)
+	if err == nil {
+		return ctx.summary.runtimeErr()
+	}
+	return err
+}
+
 func shopifyThemeClientFactory(e *env.Env) (shopifyClient, error) {
 	client, err := shopify.NewClient(e)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,6 +14,7 @@ import (
 	"github.com/Shopify/themekit/src/env"
 	"github.com/Shopify/themekit/src/file"
 	"github.com/Shopify/themekit/src/shopify"
+	"github.com/Shopify/themekit/src/state"
 )
 
 func TestCreateCtx(t *testing.T) {
@@ -84,6 +86,17 @@ func TestCtx_Err(t *testing.T) {
 	assert.NotContains(t, stdErr.String(), "[production] this is err")
 }
 
+func TestCtx_Failed(t *testing.T) {
+	ctx := Ctx{Env: &env.Env{}}
+	assert.Equal(t, []state.Failure{}, ctx.Failures())
+	ctx.Failed("snippets/b.liquid", fmt.Errorf("server error"))
+	ctx.Failed("assets/a.js", fmt.Errorf("timeout"))
+	assert.Equal(t, []state.Failure{
+		{Path: "assets/a.js", Cause: "timeout"},
+		{Path: "snippets/b.liquid", Cause: "server error"},
+	}, ctx.Failures())
+}
+
 func TestCtx_DoneTask(t *testing.T) {
 	ctx := Ctx{Env: &env.Env{}, Flags: Flags{}, progress: mpb.New(nil)}
 	assert.NotPanics(t, func() {
@@ -96,6 +109,19 @@ func TestCtx_DoneTask(t *testing.T) {
 	assert.Equal(t, ctx.Bar.Current(), int64(1))
 }
 
+func TestCtx_Confirm(t *testing.T) {
+	stdOut := bytes.NewBufferString("")
+	ctx := Ctx{Env: &env.Env{}, Log: log.New(stdOut, "", 0)}
+	assert.False(t, ctx.Confirm("Are you sure?"))
+	assert.Equal(t, "", stdOut.String())
+
+	for answer, expected := range map[string]bool{"y\n": true, "Yes\n": true, "n\n": false, "\n": false, "": false} {
+		ctx.In = strings.NewReader(answer)
+		assert.Equal(t, expected, ctx.Confirm("Are you sure?"), answer)
+	}
+	assert.Contains(t, stdOut.String(), "Are you sure? [y/N] ")
+}
+
 func TestGenerateContexts(t *testing.T) {
 	factory := func(*env.Env) (shopifyClient, error) { return nil, nil }
 	_, err := generateContexts(factory, nil, Flags{Environments: []string{"development"}}, []string{})
@@ -131,6 +157,7 @@ func TestGetFlagEnv(t *testing.T) {
 		Notify:       "n",
 		IgnoredFiles: []string{"i"},
 		Ignores:      []string{"c"},
+		Concurrency:  2,
 	}
 
 	e := env.Env{
@@ -143,6 +170,7 @@ func TestGetFlagEnv(t *testing.T) {
 		Notify:       "n",
 		IgnoredFiles: []string{"i"},
 		Ignores:      []string{"c"},
+		Concurrency:  2,
 	}
 
 	assert.Equal(t, e, getFlagEnv(flags))
@@ -151,13 +179,14 @@ func TestGetFlagEnv(t *testing.T) {
 	assert.NotEqual(t, e, getFlagEnv(flags))
 
 	e = env.Env{
-		Directory: "d",
-		Password:  "p",
-		ThemeID:   "t",
-		Domain:    "o",
-		Proxy:     "r",
-		Timeout:   1,
-		Notify:    "n",
+		Directory:   "d",
+		Password:    "p",
+		ThemeID:     "t",
+		Domain:      "o",
+		Proxy:       "r",
+		Timeout:     1,
+		Notify:      "n",
+		Concurrency: 2,
 	}
 
 	assert.Equal(t, e, getFlagEnv(flags))
@@ -239,6 +268,21 @@ func TestForEachClient(t *testing.T) {
 	err = forEachClient(factory, Flags{Environments: []string{"development"}, ConfigPath: "_testdata/config.yml"}, []string{}, handler)
 	assert.Equal(t, ErrDuringRuntime, err)
 	assert.Contains(t, stdErr.String(), "Errors encountered: ")
+
+	stdOut := bytes.NewBufferString("")
+	handler = func(ctx *Ctx) error {
+		ctx.Log = log.New(stdOut, "", 0)
+		ctx.Conflict("assets/app.js")
+		ctx.DoneTask(file.Conflict)
+		return nil
+	}
+	client = new(mocks.ShopifyClient)
+	factory = func(*env.Env) (shopifyClient, error) { return client, nil }
+	client.On("GetShop").Return(shopify.Shop{}, nil)
+	client.On("Themes").Return([]shopify.Theme{}, nil)
+	err = forEachClient(factory, Flags{Environments: []string{"development"}, ConfigPath: "_testdata/config.yml"}, []string{}, handler)
+	assert.Equal(t, ErrConflicts, err)
+	assert.Contains(t, stdOut.String(), "assets/app.js")
 }
 
 func TestForSingleClient(t *testing.T) {
@@ -351,3 +395,39 @@ func TestForDefaultClient(t *testing.T) {
 	assert.Equal(t, gandalfErr, err)
 	assert.Contains(t, stdErr.String(), "Errors encountered: ")
 }
+
+func TestForLocalEnv(t *testing.T) {
+	var ran *Ctx
+	handler := func(ctx *Ctx) error {
+		ran = ctx
+		return nil
+	}
+
+	assert.Nil(t, ForLocalEnv(Flags{ConfigPath: "_testdata/nope.yml"}, []string{"a"}, handler))
+	assert.Equal(t, "development", ran.Env.Name)
+	assert.Equal(t, []string{"a"}, ran.Args)
+	assert.Nil(t, ran.Client)
+
+	assert.Nil(t, ForLocalEnv(Flags{ConfigPath: "_testdata/config.yml", Environments: []string{"production"}}, []string{}, handler))
+	assert.Equal(t, "production", ran.Env.Name)
+	assert.Equal(t, []string{"charmander", "bulbasaur", "squirtle"}, ran.Env.IgnoredFiles)
+
+	assert.Nil(t, ForLocalEnv(Flags{ConfigPath: "_testdata/config.yml", DisableIgnore: true}, []string{}, handler))
+	assert.Equal(t, []string{}, ran.Env.IgnoredFiles)
+
+	err := ForLocalEnv(Flags{ConfigPath: "_testdata/nope.yml", Directory: "nope"}, []string{}, handler)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid project directory")
+	}
+
+	gandalfErr := fmt.Errorf("you shall not pass")
+	err = ForLocalEnv(Flags{ConfigPath: "_testdata/nope.yml"}, []string{}, func(*Ctx) error { return gandalfErr })
+	assert.Equal(t, gandalfErr, err)
+
+	err = ForLocalEnv(Flags{ConfigPath: "_testdata/nope.yml"}, []string{}, func(ctx *Ctx) error {
+		ctx.ErrLog = log.New(bytes.NewBufferString(""), "", 0)
+		ctx.Err("oopsy")
+		return nil
+	})
+	assert.Equal(t, ErrDuringRuntime, err)
+}
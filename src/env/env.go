@@ -24,6 +24,27 @@ type Env struct {
 	Timeout      time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty" env:"THEMEKIT_TIMEOUT"`
 	ReadOnly     bool          `yaml:"readonly,omitempty" json:"readonly,omitempty" env:"-"`
 	Notify       string        `yaml:"notify,omitempty" json:"notify,omitempty" env:"THEMEKIT_NOTIFY"`
+	// Concurrency is how many files are transferred at the same time
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty" env:"THEMEKIT_CONCURRENCY"`
+	// ManagedSettings are the settings_data.json paths that deploy --merge-settings
+	// will always take from the local file
+	ManagedSettings []string `yaml:"managed_settings,omitempty" json:"managed_settings,omitempty" env:"THEMEKIT_MANAGED_SETTINGS" envSeparator:":"`
+	// MaxDeletes and MaxDeletePercent limit how many files deploy will remove from
+	// shopify without confirmation, as a count and as a percentage of the files on
+	// shopify
+	MaxDeletes       int `yaml:"max_deletes,omitempty" json:"max_deletes,omitempty" env:"THEMEKIT_MAX_DELETES"`
+	MaxDeletePercent int `yaml:"max_delete_percent,omitempty" json:"max_delete_percent,omitempty" env:"THEMEKIT_MAX_DELETE_PERCENT"`
+	// ManifestKey is the asset that deploys are recorded in on the theme
+	ManifestKey string `yaml:"manifest_key,omitempty" json:"manifest_key,omitempty" env:"THEMEKIT_MANIFEST_KEY"`
+	// Deployer is the name that deploys are recorded under on the theme. Theme files
+	// are public so the user and host are never recorded.
+	Deployer string `yaml:"deployer,omitempty" json:"deployer,omitempty" env:"THEMEKIT_DEPLOYER"`
+	// SizeBudgets limit the size of the files that deploy and watch upload. Each key
+	// is a glob pattern of file names, or total for the whole theme, and each value is
+	// a size like 200KB. Files over budget are warned about, or are not uploaded when
+	// StrictSizeBudgets is set.
+	SizeBudgets       map[string]string `yaml:"size_budgets,omitempty" json:"size_budgets,omitempty" env:"-"`
+	StrictSizeBudgets bool              `yaml:"strict_size_budgets,omitempty" json:"strict_size_budgets,omitempty" env:"THEMEKIT_STRICT_SIZE_BUDGETS"`
 }
 
 //Default is the default values for a environment
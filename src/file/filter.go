@@ -3,12 +3,27 @@ package file
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/ryanuber/go-glob"
+
+	"github.com/Shopify/themekit/src/env"
 )
 
+// LockKey is the asset that themekit keeps on shopify to lock a theme while it is
+// being changed. It is never synced with the project directory.
+const LockKey = "assets/themekit_lock.json"
+
+// DefaultManifestKey is the asset that deploys are recorded in if an environment
+// does not configure a manifest_key
+const DefaultManifestKey = "assets/themekit_manifest.json"
+
+// RemoteCopyExt is appended to the name of a file when download writes the version
+// on shopify next to a conflicting local file
+const RemoteCopyExt = ".remote"
+
 var defaultRegexes = []*regexp.Regexp{
 	regexp.MustCompile(`\.git`),
 	regexp.MustCompile(`\.hg`),
@@ -23,15 +38,19 @@ var defaultRegexes = []*regexp.Regexp{
 	regexp.MustCompile(`desktop\.ini`),
 	regexp.MustCompile(`config.yml`),
 	regexp.MustCompile(`node_modules`),
+	regexp.MustCompile(`(^|/)` + regexp.QuoteMeta(LockKey) + `$`),
+	// where themekit keeps the sync state of a project
+	regexp.MustCompile(`(^|/)\.themekit/`),
 }
 
 var defaultGlobs = []string{}
 
 // Filter matches filepaths to a list of patterns
 type Filter struct {
-	rootDir string
-	regexps []*regexp.Regexp
-	globs   []string
+	rootDir  string
+	regexps  []*regexp.Regexp
+	globs    []string
+	reserved []string
 }
 
 // NewFilter will create a new file path filter
@@ -54,12 +73,39 @@ func NewFilter(rootDir string, patterns []string, files []string) (Filter, error
 	}, nil
 }
 
+// NewEnvFilter will create the file path filter for an environment, which also
+// matches the assets that themekit keeps on shopify for the environment
+func NewEnvFilter(e *env.Env) (Filter, error) {
+	filter, err := NewFilter(e.Directory, e.IgnoredFiles, e.Ignores)
+	filter.reserved = []string{ManifestKey(e)}
+	return filter, err
+}
+
+// ManifestKey will return the asset that deploys are recorded in for the environment
+func ManifestKey(e *env.Env) string {
+	if e.ManifestKey != "" {
+		return e.ManifestKey
+	}
+	return DefaultManifestKey
+}
+
 // Match will return true if the file path has matched a pattern in this filter
 func (f Filter) Match(path string) bool {
 	if len(path) == 0 || !pathInProject(f.rootDir, path) {
 		return true
 	}
 
+	key := strings.TrimPrefix(filepath.ToSlash(path), filepath.ToSlash(f.rootDir))
+	for _, reserved := range f.reserved {
+		if key == reserved {
+			return true
+		}
+	}
+
+	if f.isRemoteCopy(key) {
+		return true
+	}
+
 	for _, regexp := range f.regexps {
 		if regexp.MatchString(path) {
 			return true
@@ -75,6 +121,17 @@ func (f Filter) Match(path string) bool {
 	return false
 }
 
+// isRemoteCopy will return true if the key is a copy of a file on shopify that was
+// written next to the local file. It is only a copy while that file exists so that
+// theme files that happen to end in the same extension are not ignored.
+func (f Filter) isRemoteCopy(key string) bool {
+	if !strings.HasSuffix(key, RemoteCopyExt) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(f.rootDir, filepath.FromSlash(strings.TrimSuffix(key, RemoteCopyExt))))
+	return err == nil
+}
+
 // filesToPatterns will load up external files and scrape patterns from them
 func filesToPatterns(files []string) ([]string, error) {
 	patterns := []string{}
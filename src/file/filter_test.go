@@ -1,10 +1,15 @@
 package file
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/env"
 )
 
 func TestNewFilter(t *testing.T) {
@@ -57,6 +62,51 @@ func TestFilter_Match(t *testing.T) {
 	}
 }
 
+func TestFilter_MatchLockKey(t *testing.T) {
+	filter, err := NewFilter("/tmp", []string{}, []string{})
+	assert.Nil(t, err)
+	assert.True(t, filter.Match(LockKey))
+	assert.True(t, filter.Match("/tmp/"+LockKey))
+	assert.False(t, filter.Match("assets/my_themekit_lock.json"))
+}
+
+func TestFilter_MatchStateDir(t *testing.T) {
+	filter, err := NewFilter("/tmp", []string{}, []string{})
+	assert.Nil(t, err)
+	assert.True(t, filter.Match(".themekit/development/state.json"))
+	assert.True(t, filter.Match("/tmp/.themekit/development/state.json"))
+	assert.False(t, filter.Match("assets/.themekit.js"))
+}
+
+func TestFilter_MatchRemoteCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filter")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "assets"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("app"), 0644))
+
+	filter, err := NewFilter(dir, []string{}, []string{})
+	assert.Nil(t, err)
+	assert.True(t, filter.Match("assets/app.js"+RemoteCopyExt))
+	assert.True(t, filter.Match(filepath.Join(dir, "assets", "app.js"+RemoteCopyExt)))
+	// without the file it was copied next to it is a theme file like any other
+	assert.False(t, filter.Match("assets/font.remote"))
+	assert.False(t, filter.Match(filepath.Join(dir, "assets", "font.remote")))
+}
+
+func TestNewEnvFilter(t *testing.T) {
+	filter, err := NewEnvFilter(&env.Env{Directory: "/tmp"})
+	assert.Nil(t, err)
+	assert.True(t, filter.Match(DefaultManifestKey))
+	assert.True(t, filter.Match("/tmp/"+DefaultManifestKey))
+	assert.False(t, filter.Match("assets/app.js"))
+
+	filter, err = NewEnvFilter(&env.Env{Directory: "/tmp", ManifestKey: "assets/deploy.json"})
+	assert.Nil(t, err)
+	assert.True(t, filter.Match("assets/deploy.json"))
+	assert.False(t, filter.Match(DefaultManifestKey))
+}
+
 func TestFilesToPatterns(t *testing.T) {
 	patterns, err := filesToPatterns([]string{"_testdata/ignores_file"})
 	assert.Nil(t, err)
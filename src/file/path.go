@@ -22,6 +22,18 @@ var (
 	}
 )
 
+// IsAssetKey will return true if the key is inside of one of the directories that
+// shopify keeps theme files in
+func IsAssetKey(key string) bool {
+	return pathToProject("", key) == key
+}
+
+// IsAssetDir will return true if the directory is one of the directories that
+// shopify keeps theme files in, and not a directory inside of one of them
+func IsAssetDir(dir string) bool {
+	return isProjectDirectory("", dir)
+}
+
 func pathInProject(root, filename string) bool {
 	return pathToProject(root, filename) != "" || isProjectDirectory(root, filename)
 }
@@ -27,6 +27,33 @@ func TestPathToProject(t *testing.T) {
 	}
 }
 
+func TestIsAssetKey(t *testing.T) {
+	tests := map[string]bool{
+		"assets/logo.png":                  true,
+		"templates/customers/login.liquid": true,
+		"README.md":                        false,
+		"assets":                           false,
+		"node_modules/assets/logo.png":     false,
+		".themekit/development/state.json": false,
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, IsAssetKey(input), input)
+	}
+}
+
+func TestIsAssetDir(t *testing.T) {
+	tests := map[string]bool{
+		"assets":              true,
+		"templates/customers": true,
+		"assets/images":       false,
+		"snippets/icons":      false,
+		".":                   false,
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, IsAssetDir(input), input)
+	}
+}
+
 func TestDirInProject(t *testing.T) {
 	root := filepath.Join("long", "path", "to")
 	tests := map[string]bool{
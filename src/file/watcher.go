@@ -24,6 +24,15 @@ const (
 	Skip
 	// Get is when a file should be re-fetched, used in download operations
 	Get
+	// Conflict is when a file has changed on shopify since it was last synced and
+	// transferring it would overwrite those changes
+	Conflict
+	// Merge is when a file has changed both locally and on shopify since it was last
+	// synced and the changes can be merged
+	Merge
+	// Prune is when a local file no longer exists on shopify and is removed, used in
+	// download operations
+	Prune
 )
 
 var (
@@ -84,7 +93,7 @@ func NewWatcher(e *env.Env, configPath string, checksums map[string]string) (*Wa
 }
 
 func filterHook(e *env.Env, configPath string) (watcher.FilterFileHookFunc, error) {
-	filter, err := NewFilter(e.Directory, e.IgnoredFiles, e.Ignores)
+	filter, err := NewEnvFilter(e)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,123 @@
+// Package git finds the theme files that have changed in a git repository by
+// running the git command line tool.
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Changes are the files that have changed between a ref and the working tree.
+// Paths are relative to the directory that was diffed and use forward slashes.
+type Changes struct {
+	// Changed are the files that were added, modified or renamed to
+	Changed []string
+	// Deleted are the files that were deleted or renamed from
+	Deleted []string
+}
+
+// Diff will return the files in dir that have changed between the ref and the
+// working tree, including files that are not tracked yet. Only files within dir
+// are returned even if it is a subdirectory of the repository.
+func Diff(dir, ref string) (Changes, error) {
+	commit, err := resolve(dir, ref)
+	if err != nil {
+		return Changes{}, err
+	}
+	diff, err := run(dir, "diff", "--name-status", "-z", "-M", "--relative", commit, "--")
+	if err != nil {
+		return Changes{}, err
+	}
+	changes, err := parseNameStatus(diff)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	untracked, err := run(dir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return Changes{}, err
+	}
+	for _, path := range strings.Split(untracked, "\x00") {
+		if path != "" {
+			changes.Changed = append(changes.Changed, path)
+		}
+	}
+
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Deleted)
+	return changes, nil
+}
+
+// Commit will return the commit that is checked out in the repository that dir
+// is in
+func Commit(dir string) (string, error) {
+	out, err := run(dir, "rev-parse", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// resolve will return the commit that the ref points to. The ref is passed in by
+// the user so it is never passed to a git command that could parse it as an
+// option, like --output.
+func resolve(dir, ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid git ref %q", ref)
+	} else if _, err := run(dir, "rev-parse", "--git-dir"); err != nil {
+		return "", err
+	}
+	out, err := run(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%q is not a commit", ref)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// parseNameStatus will parse the output of git diff --name-status -z where every
+// status is followed by one path, or two for renames and copies.
+func parseNameStatus(out string) (Changes, error) {
+	changes := Changes{Changed: []string{}, Deleted: []string{}}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if status == "" {
+			continue
+		} else if i+1 >= len(fields) {
+			return changes, fmt.Errorf("unexpected git diff output after %q", status)
+		}
+
+		switch status[0] {
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return changes, fmt.Errorf("unexpected git diff output after %q", status)
+			}
+			if status[0] == 'R' {
+				changes.Deleted = append(changes.Deleted, fields[i+1])
+			}
+			changes.Changed = append(changes.Changed, fields[i+2])
+			i += 2
+		case 'D':
+			changes.Deleted = append(changes.Deleted, fields[i+1])
+			i++
+		default:
+			changes.Changed = append(changes.Changed, fields[i+1])
+			i++
+		}
+	}
+	return changes, nil
+}
+
+func run(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	changes, err := parseNameStatus("M\x00assets/app.js\x00A\x00snippets/new.liquid\x00D\x00layout/old.liquid\x00R087\x00sections/a.liquid\x00sections/b.liquid\x00C100\x00templates/a.json\x00templates/b.json\x00")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"assets/app.js", "snippets/new.liquid", "sections/b.liquid", "templates/b.json"}, changes.Changed)
+	assert.Equal(t, []string{"layout/old.liquid", "sections/a.liquid"}, changes.Deleted)
+
+	changes, err = parseNameStatus("")
+	assert.Nil(t, err)
+	assert.Equal(t, Changes{Changed: []string{}, Deleted: []string{}}, changes)
+
+	_, err = parseNameStatus("R100\x00sections/a.liquid\x00")
+	assert.NotNil(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo, err := ioutil.TempDir("", "themekit-git")
+	assert.Nil(t, err)
+	defer os.RemoveAll(repo)
+	theme := filepath.Join(repo, "theme")
+
+	write := func(path, contents string) {
+		path = filepath.Join(theme, filepath.FromSlash(path))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(out))
+	}
+
+	write("assets/app.js", "app")
+	write("snippets/keep.liquid", "keep")
+	write("snippets/old.liquid", "a snippet that is long enough to be detected as renamed")
+	write("layout/theme.liquid", "layout")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(repo, "README.md"), []byte("readme"), 0644))
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	write("assets/app.js", "changed")
+	assert.Nil(t, os.Remove(filepath.Join(theme, "layout", "theme.liquid")))
+	assert.Nil(t, os.Rename(filepath.Join(theme, "snippets", "old.liquid"), filepath.Join(theme, "snippets", "new.liquid")))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(repo, "README.md"), []byte("changed"), 0644))
+	git("add", "-A")
+	write("templates/index.liquid", "untracked")
+
+	changes, err := Diff(theme, "HEAD")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"assets/app.js", "snippets/new.liquid", "templates/index.liquid"}, changes.Changed)
+	assert.Equal(t, []string{"layout/theme.liquid", "snippets/old.liquid"}, changes.Deleted)
+
+	_, err = Diff(theme, "nope")
+	assert.NotNil(t, err)
+
+	output := filepath.Join(repo, "output")
+	_, err = Diff(theme, "--output="+output)
+	assert.NotNil(t, err)
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+
+	commit, err := Commit(theme)
+	assert.Nil(t, err)
+	assert.Len(t, commit, 40)
+
+	_, err = Commit(os.TempDir())
+	assert.NotNil(t, err)
+}
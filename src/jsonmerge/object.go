@@ -0,0 +1,156 @@
+package jsonmerge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// object is a json object that keeps the order of its keys, so that merging a
+// document does not reorder every key in it. Shopify uses the order of some
+// objects, like the sections of a template.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: map[string]interface{}{}}
+}
+
+func (o *object) get(key string) (interface{}, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// set will add the value under the key, keeping the position of the key if it is
+// already in the object
+func (o *object) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// MarshalJSON will write the object with its keys in order
+func (o *object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := marshalInto(&out, key); err != nil {
+			return nil, err
+		}
+		out.WriteByte(':')
+		if err := marshalInto(&out, o.values[key]); err != nil {
+			return nil, err
+		}
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+func marshalInto(out *bytes.Buffer, data interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+	// Encode always ends with a newline
+	out.Truncate(out.Len() - 1)
+	return nil
+}
+
+// orderedKeys will return the keys of a followed by the keys that are only in b
+func orderedKeys(a, b *object) []string {
+	keys := append([]string{}, a.keys...)
+	for _, key := range b.keys {
+		if _, ok := a.values[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// equal will return true if the decoded json values are the same, no matter the
+// order of the keys of their objects
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *object:
+		b, ok := b.(*object)
+		if !ok || len(a.keys) != len(b.keys) {
+			return false
+		}
+		for key, value := range a.values {
+			if other, ok := b.values[key]; !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// decodeValue will read the next json value, decoding objects as ordered objects
+// and numbers as json.Number so that they are written back as they were
+func decodeValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := newObject()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), value)
+		}
+		return obj, closeDelim(decoder)
+	case '[':
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, closeDelim(decoder)
+	}
+	return nil, fmt.Errorf("unexpected %s", delim)
+}
+
+func closeDelim(decoder *json.Decoder) error {
+	if _, err := decoder.Token(); err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package jsonmerge merges the different versions of json theme files, like
+// config/settings_data.json, so that changes made on shopify are not lost when
+// local changes are uploaded.
+package jsonmerge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ryanuber/go-glob"
+)
+
+// Settings will deep merge the local and remote versions of a settings document.
+// Values in the remote document win unless their path matches one of the managed
+// patterns, in which case the local value is used, or does not exist remotely.
+// Paths are object keys joined with a '.', like `current.colors_accent`, and
+// patterns may use '*' as a wildcard. A managed path that does not exist locally
+// is removed from the result.
+func Settings(local, remote []byte, managed []string) ([]byte, error) {
+	localDoc, err := decode(local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local settings: %s", err)
+	}
+	remoteDoc, err := decode(remote)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote settings: %s", err)
+	}
+
+	merged, _ := mergeSettings("", localDoc, remoteDoc, true, true, managed)
+	return encode(merged)
+}
+
+func mergeSettings(path string, local, remote interface{}, onLocal, onRemote bool, managed []string) (interface{}, bool) {
+	if path != "" && isManaged(path, managed) {
+		return local, onLocal
+	} else if !onRemote {
+		return local, onLocal
+	}
+
+	localObj, localIsObj := local.(*object)
+	remoteObj, remoteIsObj := remote.(*object)
+	if !onLocal || !localIsObj || !remoteIsObj {
+		// managed paths may be nested inside of a value that is only on shopify so
+		// the remote value still needs to be walked when it is an object
+		if !remoteIsObj {
+			return remote, true
+		}
+		localObj = newObject()
+	}
+
+	merged := newObject()
+	for _, key := range orderedKeys(localObj, remoteObj) {
+		localValue, inLocal := localObj.get(key)
+		remoteValue, inRemote := remoteObj.get(key)
+		if value, ok := mergeSettings(joinPath(path, key), localValue, remoteValue, inLocal, inRemote, managed); ok {
+			merged.set(key, value)
+		}
+	}
+	return merged, true
+}
+
+func isManaged(path string, managed []string) bool {
+	for _, pattern := range managed {
+		if glob.Glob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decodeValue(decoder)
+}
+
+func encode(doc interface{}) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimSuffix(out.String(), "\n")), nil
+}
@@ -0,0 +1,76 @@
+package jsonmerge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettings(t *testing.T) {
+	testcases := []struct {
+		local, remote, expected, err string
+		managed                      []string
+	}{
+		{
+			local:    `{"current": {"color": "red", "logo": "a.png"}}`,
+			remote:   `{"current": {"color": "blue", "logo": "b.png", "banner": "x"}}`,
+			expected: `{"current": {"banner": "x", "color": "blue", "logo": "b.png"}}`,
+		},
+		{
+			local:    `{"current": {"color": "red", "logo": "a.png", "font": "serif"}}`,
+			remote:   `{"current": {"color": "blue", "logo": "b.png"}}`,
+			managed:  []string{"current.color"},
+			expected: `{"current": {"color": "red", "font": "serif", "logo": "b.png"}}`,
+		},
+		{
+			local:    `{"current": {"sections": {"header": {"type": "header", "settings": {"a": 1}}}}}`,
+			remote:   `{"current": {"sections": {"header": {"type": "header", "settings": {"a": 2, "b": 3}}}}}`,
+			managed:  []string{"current.sections.*"},
+			expected: `{"current": {"sections": {"header": {"settings": {"a": 1}, "type": "header"}}}}`,
+		},
+		{
+			local:    `{"current": {}}`,
+			remote:   `{"current": {"color": "blue", "logo": "b.png"}}`,
+			managed:  []string{"current.color"},
+			expected: `{"current": {"logo": "b.png"}}`,
+		},
+		{
+			local:    `{"current": "Default", "presets": {"Default": {"color": "red"}}}`,
+			remote:   `{"current": {"color": "blue"}, "presets": {"Default": {"color": "green"}}}`,
+			managed:  []string{"presets.Default.color"},
+			expected: `{"current": {"color": "blue"}, "presets": {"Default": {"color": "red"}}}`,
+		},
+		{
+			local:    `{"price": 10.50, "url": "/a?b=c&d"}`,
+			remote:   `{}`,
+			expected: `{"price": 10.50, "url": "/a?b=c&d"}`,
+		},
+		{local: `{`, remote: `{}`, err: "invalid local settings"},
+		{local: `{}`, remote: `nope`, err: "invalid remote settings"},
+	}
+
+	for i, testcase := range testcases {
+		merged, err := Settings([]byte(testcase.local), []byte(testcase.remote), testcase.managed)
+		if testcase.err != "" {
+			if assert.NotNil(t, err, i) {
+				assert.Contains(t, err.Error(), testcase.err, i)
+			}
+			continue
+		}
+		assert.Nil(t, err, i)
+		assert.JSONEq(t, testcase.expected, string(merged), i)
+		assert.Contains(t, string(merged), "\n  ", i)
+	}
+}
+
+func TestSettingsKeepsKeyOrder(t *testing.T) {
+	merged, err := Settings([]byte(`{"current": {"logo": "a.png", "color": "red"}}`), []byte(`{"current": {"color": "blue", "banner": "x", "logo": "b.png"}}`), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "current": {
+    "logo": "b.png",
+    "color": "blue",
+    "banner": "x"
+  }
+}`, string(merged))
+}
@@ -0,0 +1,120 @@
+package jsonmerge
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+)
+
+var mergeableDirs = []string{"templates", "templates/customers", "locales"}
+
+// Conflict describes a value that was changed differently in the local and remote
+// documents. The values are left out if they did not exist in that document.
+type Conflict struct {
+	Path   string          `json:"path"`
+	Base   json.RawMessage `json:"base,omitempty"`
+	Local  json.RawMessage `json:"local,omitempty"`
+	Remote json.RawMessage `json:"remote,omitempty"`
+}
+
+// Mergeable will return true if the asset is a json file that is edited both
+// locally and on shopify, and so can be merged with ThreeWay.
+func Mergeable(key string) bool {
+	if path.Ext(key) != ".json" {
+		return false
+	}
+	dir := path.Dir(key)
+	for _, mergeableDir := range mergeableDirs {
+		if dir == mergeableDir {
+			return true
+		}
+	}
+	return false
+}
+
+// ThreeWay will merge the local and remote versions of a json document key by key
+// using base, the version they were both changed from. Changes made in only one
+// of the documents are kept. Values that were changed in both documents to
+// something different are returned as conflicts and keep their local value in the
+// merged document.
+func ThreeWay(base, local, remote []byte) ([]byte, []Conflict, error) {
+	baseDoc, err := decode(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid base version: %s", err)
+	}
+	localDoc, err := decode(local)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid local version: %s", err)
+	}
+	remoteDoc, err := decode(remote)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid remote version: %s", err)
+	}
+
+	conflicts := []Conflict{}
+	merged, _ := mergeThreeWay("", value{baseDoc, true}, value{localDoc, true}, value{remoteDoc, true}, &conflicts)
+	out, err := encode(merged)
+	return out, conflicts, err
+}
+
+// value is a json value and whether it exists in its document at all
+type value struct {
+	data   interface{}
+	exists bool
+}
+
+func (v value) equal(other value) bool {
+	return v.exists == other.exists && equal(v.data, other.data)
+}
+
+func (v value) object() (*object, bool) {
+	obj, ok := v.data.(*object)
+	return obj, v.exists && ok
+}
+
+func (v value) field(key string) value {
+	obj, ok := v.object()
+	if !ok {
+		return value{}
+	}
+	data, exists := obj.get(key)
+	return value{data, exists}
+}
+
+func (v value) raw() json.RawMessage {
+	if !v.exists {
+		return nil
+	}
+	data, _ := json.Marshal(v.data)
+	return data
+}
+
+func mergeThreeWay(path string, base, local, remote value, conflicts *[]Conflict) (interface{}, bool) {
+	switch {
+	case local.equal(remote), base.equal(remote):
+		return local.data, local.exists
+	case base.equal(local):
+		return remote.data, remote.exists
+	}
+
+	localObj, localIsObj := local.object()
+	remoteObj, remoteIsObj := remote.object()
+	if localIsObj && remoteIsObj {
+		merged := newObject()
+		for _, key := range orderedKeys(localObj, remoteObj) {
+			keyPath := joinPath(path, key)
+			if data, exists := mergeThreeWay(keyPath, base.field(key), local.field(key), remote.field(key), conflicts); exists {
+				merged.set(key, data)
+			}
+		}
+		return merged, true
+	}
+
+	*conflicts = append(*conflicts, Conflict{
+		Path:   path,
+		Base:   base.raw(),
+		Local:  local.raw(),
+		Remote: remote.raw(),
+	})
+	return local.data, local.exists
+}
@@ -0,0 +1,116 @@
+package jsonmerge
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeable(t *testing.T) {
+	assert.True(t, Mergeable("templates/index.json"))
+	assert.True(t, Mergeable("templates/customers/account.json"))
+	assert.True(t, Mergeable("locales/en.default.json"))
+	assert.False(t, Mergeable("templates/index.liquid"))
+	assert.False(t, Mergeable("config/settings_data.json"))
+	assert.False(t, Mergeable("assets/data.json"))
+}
+
+func TestThreeWay(t *testing.T) {
+	testcases := []struct {
+		base, local, remote, expected string
+		conflicts                     []Conflict
+		err                           string
+	}{
+		{
+			base:     `{"a": 1, "b": 1}`,
+			local:    `{"a": 2, "b": 1}`,
+			remote:   `{"a": 1, "b": 2}`,
+			expected: `{"a": 2, "b": 2}`,
+		},
+		{
+			base:     `{"sections": {"main": {"type": "main"}}, "order": ["main"]}`,
+			local:    `{"sections": {"main": {"type": "main"}, "hero": {"type": "hero"}}, "order": ["main"]}`,
+			remote:   `{"sections": {"main": {"type": "main", "settings": {"a": true}}}, "order": ["main"]}`,
+			expected: `{"sections": {"main": {"type": "main", "settings": {"a": true}}, "hero": {"type": "hero"}}, "order": ["main"]}`,
+		},
+		{
+			base:     `{"a": 1, "b": 1}`,
+			local:    `{"b": 1}`,
+			remote:   `{"a": 1, "b": 1, "c": 3}`,
+			expected: `{"b": 1, "c": 3}`,
+		},
+		{
+			base:     `{"title": "Hello", "other": 1}`,
+			local:    `{"title": "Hi", "other": 1}`,
+			remote:   `{"title": "Hey", "other": 2}`,
+			expected: `{"title": "Hi", "other": 2}`,
+			conflicts: []Conflict{
+				{Path: "title", Base: json.RawMessage(`"Hello"`), Local: json.RawMessage(`"Hi"`), Remote: json.RawMessage(`"Hey"`)},
+			},
+		},
+		{
+			base:     `{"a": {"b": 1}}`,
+			local:    `{}`,
+			remote:   `{"a": {"b": 2}}`,
+			expected: `{}`,
+			conflicts: []Conflict{
+				{Path: "a", Base: json.RawMessage(`{"b":1}`), Remote: json.RawMessage(`{"b":2}`)},
+			},
+		},
+		{base: `{`, local: `{}`, remote: `{}`, err: "invalid base version"},
+		{base: `{}`, local: `{`, remote: `{}`, err: "invalid local version"},
+		{base: `{}`, local: `{}`, remote: `{`, err: "invalid remote version"},
+	}
+
+	for i, testcase := range testcases {
+		merged, conflicts, err := ThreeWay([]byte(testcase.base), []byte(testcase.local), []byte(testcase.remote))
+		if testcase.err != "" {
+			if assert.NotNil(t, err, i) {
+				assert.Contains(t, err.Error(), testcase.err, i)
+			}
+			continue
+		}
+		assert.Nil(t, err, i)
+		assert.JSONEq(t, testcase.expected, string(merged), i)
+		if testcase.conflicts == nil {
+			testcase.conflicts = []Conflict{}
+		}
+		assert.Equal(t, testcase.conflicts, conflicts, i)
+	}
+}
+
+func TestThreeWayKeepsKeyOrder(t *testing.T) {
+	base := `{"sections": {"hero": {"type": "hero"}, "main": {"type": "main"}}, "order": ["hero", "main"]}`
+	local := `{"sections": {"hero": {"type": "hero"}, "main": {"type": "main"}, "footer": {"type": "footer"}}, "order": ["hero", "main", "footer"]}`
+	remote := `{"order": ["hero", "main"], "sections": {"main": {"type": "main"}, "hero": {"type": "hero", "settings": {"title": "<b>Hi</b>", "z": 1, "a": 2}}, "banner": {"type": "banner"}}}`
+	merged, conflicts, err := ThreeWay([]byte(base), []byte(local), []byte(remote))
+	assert.Nil(t, err)
+	assert.Equal(t, []Conflict{}, conflicts)
+	assert.Equal(t, `{
+  "sections": {
+    "hero": {
+      "type": "hero",
+      "settings": {
+        "title": "<b>Hi</b>",
+        "z": 1,
+        "a": 2
+      }
+    },
+    "main": {
+      "type": "main"
+    },
+    "footer": {
+      "type": "footer"
+    },
+    "banner": {
+      "type": "banner"
+    }
+  },
+  "order": [
+    "hero",
+    "main",
+    "footer"
+  ]
+}`, string(merged))
+}
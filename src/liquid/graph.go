@@ -0,0 +1,138 @@
+package liquid
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// rootDirs are the directories of the files that shopify renders on its own, so
+// they are used even when nothing references them
+var rootDirs = []string{"config/", "layout/", "locales/", "templates/"}
+
+// Graph is how the files of a theme reference each other
+type Graph struct {
+	keys map[string]bool
+	refs []Reference
+	from map[string][]string
+	to   map[string][]string
+}
+
+// NewGraph will build the graph of the theme files with the keys. Only liquid and
+// json files are read, with read, to find their references.
+func NewGraph(keys []string, read func(key string) ([]byte, error)) (*Graph, error) {
+	g := &Graph{keys: map[string]bool{}, from: map[string][]string{}, to: map[string][]string{}}
+	for _, key := range keys {
+		g.keys[key] = true
+	}
+
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		if ext := path.Ext(key); ext != ".liquid" && ext != ".json" {
+			continue
+		}
+		contents, err := read(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range Scan(key, contents) {
+			ref.To = g.resolve(ref.To)
+			g.refs = append(g.refs, ref)
+			g.from[key] = append(g.from[key], ref.To)
+			g.to[ref.To] = append(g.to[ref.To], key)
+		}
+	}
+	return g, nil
+}
+
+// Missing will return the references to files that are not in the theme, sorted
+// by the file they are in
+func (g *Graph) Missing() []Reference {
+	missing := []Reference{}
+	for _, ref := range g.refs {
+		if !g.keys[ref.To] {
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
+
+// Unused will return the sorted keys of the snippets, sections and assets that
+// are not referenced by any template or layout, either directly or through other
+// files
+func (g *Graph) Unused() []string {
+	used := map[string]bool{}
+	var visit func(key string)
+	visit = func(key string) {
+		if used[key] {
+			return
+		}
+		used[key] = true
+		for _, ref := range g.from[key] {
+			visit(ref)
+		}
+	}
+	for key := range g.keys {
+		if isRoot(key) {
+			visit(key)
+		}
+	}
+
+	unused := []string{}
+	for key := range g.keys {
+		if !used[key] && isReferenced(key) {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// Dependents will return the sorted keys of the templates and layouts that
+// reference the file with the key, either directly or through other files
+func (g *Graph) Dependents(key string) []string {
+	seen := map[string]bool{}
+	dependents := []string{}
+	var visit func(key string)
+	visit = func(key string) {
+		for _, from := range g.to[key] {
+			if seen[from] {
+				continue
+			}
+			seen[from] = true
+			if strings.HasPrefix(from, "templates/") || strings.HasPrefix(from, "layout/") {
+				dependents = append(dependents, from)
+			}
+			visit(from)
+		}
+	}
+	visit(g.resolve(key))
+	sort.Strings(dependents)
+	return dependents
+}
+
+// resolve will return the key of the file that a reference is to. An asset can be
+// compiled from a liquid file, so a reference to theme.css is to theme.css.liquid
+// if only it exists.
+func (g *Graph) resolve(key string) string {
+	if !g.keys[key] && strings.HasPrefix(key, "assets/") && g.keys[key+".liquid"] {
+		return key + ".liquid"
+	}
+	return key
+}
+
+// isReferenced will return true for the files that are only used when another
+// file references them
+func isReferenced(key string) bool {
+	return strings.HasPrefix(key, "assets/") || strings.HasPrefix(key, "sections/") || strings.HasPrefix(key, "snippets/")
+}
+
+func isRoot(key string) bool {
+	for _, dir := range rootDirs {
+		if strings.HasPrefix(key, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package liquid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var theme = map[string]string{
+	"layout/theme.liquid":       "{{ 'theme.css' | asset_url | stylesheet_tag }}\n{% section 'header' %}",
+	"templates/index.json":      `{"sections": {"main": {"type": "featured"}}}`,
+	"templates/product.liquid":  "{% render 'price' %}\n{% render 'missing' %}",
+	"sections/header.liquid":    "{% render 'icon' %}",
+	"sections/featured.liquid":  "{% render 'price' %}",
+	"sections/old.liquid":       "{% render 'old-card' %}",
+	"snippets/price.liquid":     "{{ price }}",
+	"snippets/icon.liquid":      "<svg></svg>",
+	"snippets/old-card.liquid":  "{{ 'old.png' | asset_url }}",
+	"assets/theme.css.liquid":   "body {}",
+	"assets/old.png":            "",
+	"assets/unused.js":          "",
+	"config/settings_data.json": "{}",
+}
+
+func newTestGraph(t *testing.T) *Graph {
+	keys := []string{}
+	for key := range theme {
+		keys = append(keys, key)
+	}
+	g, err := NewGraph(keys, func(key string) ([]byte, error) { return []byte(theme[key]), nil })
+	assert.Nil(t, err)
+	return g
+}
+
+func TestNewGraph(t *testing.T) {
+	readErr := errors.New("cannot read")
+	_, err := NewGraph([]string{"assets/app.js", "layout/theme.liquid"}, func(key string) ([]byte, error) {
+		assert.Equal(t, "layout/theme.liquid", key)
+		return nil, readErr
+	})
+	assert.Equal(t, readErr, err)
+}
+
+func TestGraph_Missing(t *testing.T) {
+	assert.Equal(t, []Reference{
+		{From: "templates/product.liquid", To: "snippets/missing.liquid", Line: 2},
+	}, newTestGraph(t).Missing())
+}
+
+func TestGraph_Unused(t *testing.T) {
+	assert.Equal(t, []string{
+		"assets/old.png",
+		"assets/unused.js",
+		"sections/old.liquid",
+		"snippets/old-card.liquid",
+	}, newTestGraph(t).Unused())
+}
+
+func TestGraph_Dependents(t *testing.T) {
+	g := newTestGraph(t)
+	assert.Equal(t, []string{"templates/index.json", "templates/product.liquid"}, g.Dependents("snippets/price.liquid"))
+	assert.Equal(t, []string{"layout/theme.liquid"}, g.Dependents("snippets/icon.liquid"))
+	assert.Equal(t, []string{"layout/theme.liquid"}, g.Dependents("assets/theme.css.liquid"))
+	assert.Equal(t, []string{"layout/theme.liquid"}, g.Dependents("assets/theme.css"))
+	assert.Equal(t, []string{}, g.Dependents("snippets/old-card.liquid"))
+}
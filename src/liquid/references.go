@@ -0,0 +1,117 @@
+// Package liquid reads theme files to find how they depend on each other.
+package liquid
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tagPattern matches render, include, section and sections tags, both as a
+// regular tag and as a line within a {% liquid %} tag.
+var tagPattern = regexp.MustCompile(`(?m)(?:\{%-?|^)\s*(render|include|sections|section)\s+['"]([^'"]+)['"]`)
+
+// jsonTemplate is the part of a json template, or section group, that references
+// sections
+type jsonTemplate struct {
+	Sections map[string]struct {
+		Type string `json:"type"`
+	} `json:"sections"`
+}
+
+// assetPattern matches a quoted asset name passed to the asset_url or
+// asset_img_url filters
+var assetPattern = regexp.MustCompile(`['"]([^'"{}]+)['"]\s*\|\s*(?:asset_url|asset_img_url)\b`)
+
+// Reference is a place where one theme file refers to another
+type Reference struct {
+	From string
+	To   string
+	// Line is the line of From that the reference is on, or 0 for json templates
+	Line int
+}
+
+// References will return the keys of the theme files that the file with the
+// provided key and contents renders. Liquid files are scanned for render,
+// include, section and sections tags and json templates for the types of their
+// sections. The keys are returned sorted and without duplicates.
+func References(key string, contents []byte) []string {
+	found := map[string]bool{}
+	for _, ref := range Scan(key, contents) {
+		if !strings.HasPrefix(ref.To, "assets/") {
+			found[ref.To] = true
+		}
+	}
+
+	refs := []string{}
+	for ref := range found {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Scan will return every reference in the file with the provided key and
+// contents, in the order they are found. Along with what References finds, liquid
+// files are scanned for the assets passed to asset_url and asset_img_url.
+func Scan(key string, contents []byte) []Reference {
+	refs := []Reference{}
+	switch path.Ext(key) {
+	case ".liquid":
+		for _, match := range tagPattern.FindAllSubmatchIndex(contents, -1) {
+			refs = append(refs, Reference{
+				From: key,
+				To:   tagTarget(string(contents[match[2]:match[3]]), string(contents[match[4]:match[5]])),
+				Line: lineAt(contents, match[2]),
+			})
+		}
+		for _, match := range assetPattern.FindAllSubmatchIndex(contents, -1) {
+			refs = append(refs, Reference{
+				From: key,
+				To:   "assets/" + string(contents[match[2]:match[3]]),
+				Line: lineAt(contents, match[2]),
+			})
+		}
+		sort.SliceStable(refs, func(i, j int) bool { return refs[i].Line < refs[j].Line })
+	case ".json":
+		if !isJSONTemplate(key) {
+			break
+		}
+		var tmpl jsonTemplate
+		if err := json.Unmarshal(contents, &tmpl); err != nil {
+			break
+		}
+		types := []string{}
+		for _, section := range tmpl.Sections {
+			if section.Type != "" {
+				types = append(types, section.Type)
+			}
+		}
+		sort.Strings(types)
+		for _, sectionType := range types {
+			refs = append(refs, Reference{From: key, To: tagTarget("section", sectionType)})
+		}
+	}
+	return refs
+}
+
+func lineAt(contents []byte, offset int) int {
+	return bytes.Count(contents[:offset], []byte("\n")) + 1
+}
+
+func tagTarget(tag, name string) string {
+	switch tag {
+	case "section":
+		return "sections/" + name + ".liquid"
+	case "sections":
+		return "sections/" + name + ".json"
+	}
+	return "snippets/" + name + ".liquid"
+}
+
+func isJSONTemplate(key string) bool {
+	return strings.HasPrefix(key, "templates/") || strings.HasPrefix(key, "sections/")
+}
@@ -0,0 +1,62 @@
+package liquid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferences(t *testing.T) {
+	testcases := []struct {
+		key, contents string
+		expected      []string
+	}{
+		{
+			key:      "layout/theme.liquid",
+			contents: `{% section 'header' %}{%- render "icon", name: 'cart' -%}{% include 'social' %}{% sections 'footer-group' %}{% render 'icon' %}`,
+			expected: []string{"sections/footer-group.json", "sections/header.liquid", "snippets/icon.liquid", "snippets/social.liquid"},
+		},
+		{
+			key:      "snippets/card.liquid",
+			contents: "{%- liquid\n  assign x = 1\n  render 'price', product: product\n-%}",
+			expected: []string{"snippets/price.liquid"},
+		},
+		{
+			key:      "snippets/card.liquid",
+			contents: `{% render product.template %}{{ 'render "nope"' }}`,
+			expected: []string{},
+		},
+		{
+			key:      "templates/index.json",
+			contents: `{"sections": {"main": {"type": "main-product"}, "hero": {"type": "image-banner"}}, "order": ["main", "hero"]}`,
+			expected: []string{"sections/image-banner.liquid", "sections/main-product.liquid"},
+		},
+		{
+			key:      "sections/header-group.json",
+			contents: `{"type": "header", "sections": {"header": {"type": "header"}}}`,
+			expected: []string{"sections/header.liquid"},
+		},
+		{key: "templates/index.json", contents: `{`, expected: []string{}},
+		{key: "locales/en.default.json", contents: `{"sections": {"a": {"type": "b"}}}`, expected: []string{}},
+		{key: "assets/app.js", contents: `{% render 'x' %}`, expected: []string{}},
+	}
+
+	for i, testcase := range testcases {
+		assert.Equal(t, testcase.expected, References(testcase.key, []byte(testcase.contents)), i)
+	}
+}
+
+func TestScan(t *testing.T) {
+	contents := "{{ 'theme.css' | asset_url | stylesheet_tag }}\n{% render 'icon' %}\n<img src=\"{{ 'logo.png' | asset_img_url: '100x' }}\">{{ name | asset_url }}"
+	assert.Equal(t, []Reference{
+		{From: "layout/theme.liquid", To: "assets/theme.css", Line: 1},
+		{From: "layout/theme.liquid", To: "snippets/icon.liquid", Line: 2},
+		{From: "layout/theme.liquid", To: "assets/logo.png", Line: 3},
+	}, Scan("layout/theme.liquid", []byte(contents)))
+	assert.Equal(t, []string{"snippets/icon.liquid"}, References("layout/theme.liquid", []byte(contents)))
+
+	assert.Equal(t, []Reference{
+		{From: "templates/index.json", To: "sections/hero.liquid"},
+		{From: "templates/index.json", To: "sections/main.liquid"},
+	}, Scan("templates/index.json", []byte(`{"sections": {"b": {"type": "main"}, "a": {"type": "hero"}}}`)))
+}
@@ -7,11 +7,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Shopify/themekit/src/env"
 	"github.com/Shopify/themekit/src/file"
@@ -28,11 +30,43 @@ type Asset struct {
 	UpdatedAt   string `json:"updated_at,omitempty"`
 }
 
+// LocalAsset is an asset in the project directory that has had its checksum
+// calculated but has not had its contents read into memory.
+type LocalAsset struct {
+	Key      string
+	Checksum string
+}
+
+// ChecksumCache keeps the checksums of local files so that files that have not
+// changed since they were last scanned do not need to be read again
+type ChecksumCache interface {
+	LocalChecksum(key string, size int64, modTime time.Time) (string, bool)
+	SetLocalChecksum(key string, size int64, modTime time.Time, checksum string)
+}
+
+// sniffLen is how much of a file is needed to detect its content type
+const sniffLen = 512
+
 var (
 	// ErrAssetIsDir is the error returned if you try and load a directory with ReadAsset
 	ErrAssetIsDir = errors.New("requested asset is a directory")
 )
 
+// NewAsset will build an asset from the contents of a file, setting either its
+// value or attachment and calculating its checksum the same way shopify does.
+func NewAsset(key string, contents []byte) Asset {
+	asset := Asset{Key: key}
+	contentType := http.DetectContentType(contents)
+	if strings.Contains(contentType, "text") {
+		asset.Value = string(contents)
+		asset.Checksum = calculateTextChecksum(asset.Value, filepath.Ext(asset.Key) == ".json")
+	} else {
+		asset.Attachment = base64.StdEncoding.EncodeToString(contents)
+		asset.Checksum = calculateByteArrayChecksum(contents)
+	}
+	return asset
+}
+
 // ReadAsset will read a single asset from disk
 func ReadAsset(e *env.Env, filename string) (Asset, error) {
 	return readAsset(e.Directory, filename)
@@ -42,7 +76,7 @@ func ReadAsset(e *env.Env, filename string) (Asset, error) {
 // read directories recursively. If no paths are passed in then the whole project
 // directory will be read
 func FindAssets(e *env.Env, paths ...string) (assets []Asset, err error) {
-	filter, err := file.NewFilter(e.Directory, e.IgnoredFiles, e.Ignores)
+	filter, err := file.NewEnvFilter(e)
 	if err != nil {
 		return []Asset{}, err
 	}
@@ -69,6 +103,66 @@ func FindAssets(e *env.Env, paths ...string) (assets []Asset, err error) {
 	return assets, nil
 }
 
+// ScanAssets will find all assets for the paths passed in the same way as
+// FindAssets, but will only calculate their checksums. Files are streamed through
+// the hash so that only json files are ever held in memory, and the contents of
+// an asset can be read later with Read if it needs to be uploaded. Files that are
+// unchanged in the cache, which may be nil, are not read at all.
+func ScanAssets(e *env.Env, cache ChecksumCache, paths ...string) (assets []LocalAsset, err error) {
+	filter, err := file.NewEnvFilter(e)
+	if err != nil {
+		return []LocalAsset{}, err
+	}
+
+	if len(paths) == 0 {
+		return scanAssetsInDirectory(e, cache, "", filter.Match)
+	}
+
+	for _, path := range paths {
+		asset, err := scanAsset(e.Directory, cache, path)
+		if err == ErrAssetIsDir {
+			dirAssets, err := scanAssetsInDirectory(e, cache, path, filter.Match)
+			if err != nil {
+				return []LocalAsset{}, err
+			}
+			assets = append(assets, dirAssets...)
+		} else if err != nil {
+			return []LocalAsset{}, err
+		} else if !filter.Match(asset.Key) {
+			assets = append(assets, asset)
+		}
+	}
+
+	return assets, nil
+}
+
+// AssetKeys will return the key of every file in the project directory that is
+// not ignored, without reading any of them
+func AssetKeys(e *env.Env) ([]string, error) {
+	filter, err := file.NewEnvFilter(e)
+	if err != nil {
+		return []string{}, err
+	}
+	keys := []string{}
+	err = walkAssets(e.Directory, "", filter.Match, func(assetKey string) error {
+		keys = append(keys, assetKey)
+		return nil
+	})
+	return keys, err
+}
+
+// ReadChecksum will calculate the checksum of a single asset on disk without
+// reading the whole file into memory, unless it is unchanged in the cache.
+func ReadChecksum(e *env.Env, cache ChecksumCache, filename string) (string, error) {
+	asset, err := scanAsset(e.Directory, cache, filename)
+	return asset.Checksum, err
+}
+
+// Read will read the contents of the local asset from disk
+func (asset LocalAsset) Read(e *env.Env) (Asset, error) {
+	return ReadAsset(e, asset.Key)
+}
+
 // Write will write the asset out to the destination directory
 func (asset Asset) Write(directory string) error {
 	perms, err := os.Stat(directory)
@@ -89,7 +183,7 @@ func (asset Asset) Write(directory string) error {
 	defer file.Sync()
 	defer file.Close()
 
-	contents, err := asset.contents()
+	contents, err := asset.Contents()
 	if err != nil {
 		return err
 	}
@@ -98,7 +192,9 @@ func (asset Asset) Write(directory string) error {
 	return err
 }
 
-func (asset Asset) contents() ([]byte, error) {
+// Contents will return the contents of the asset as they would be written to disk,
+// with attachments decoded and json indented
+func (asset Asset) Contents() ([]byte, error) {
 	var data []byte
 	var err error
 	switch {
@@ -126,8 +222,29 @@ func assetsToFilenames(assets []Asset) []string {
 }
 
 func loadAssetsFromDirectory(e *env.Env, dir string, ignore func(path string) bool) (assets []Asset, err error) {
-	var root = e.Directory
-	err = filepath.Walk(filepath.Join(root, dir), func(path string, info os.FileInfo, err error) error {
+	err = walkAssets(e.Directory, dir, ignore, func(assetKey string) error {
+		var asset, _ = ReadAsset(e, assetKey) // TODO handle error
+		assets = append(assets, asset)
+		return nil
+	})
+	return
+}
+
+func scanAssetsInDirectory(e *env.Env, cache ChecksumCache, dir string, ignore func(path string) bool) (assets []LocalAsset, err error) {
+	err = walkAssets(e.Directory, dir, ignore, func(assetKey string) error {
+		asset, err := scanAsset(e.Directory, cache, assetKey)
+		if err != nil {
+			return err
+		}
+		assets = append(assets, asset)
+		return nil
+	})
+	return
+}
+
+// walkAssets will call found with the key of every file in dir that is not ignored
+func walkAssets(root, dir string, ignore func(path string) bool, found func(assetKey string) error) error {
+	return filepath.Walk(filepath.Join(root, dir), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -140,12 +257,10 @@ func loadAssetsFromDirectory(e *env.Env, dir string, ignore func(path string) bo
 		}
 		assetKey = filepath.ToSlash(assetKey)
 		if !ignore(assetKey) {
-			var asset, _ = ReadAsset(e, assetKey) // TODO handle error
-			assets = append(assets, asset)
+			return found(assetKey)
 		}
 		return nil
 	})
-	return
 }
 
 func readAsset(root, filename string) (asset Asset, err error) {
@@ -156,7 +271,6 @@ func readAsset(root, filename string) (asset Asset, err error) {
 		return Asset{}, err
 	}
 
-	asset = Asset{Key: filepath.ToSlash(key)}
 	file, err := os.Open(path)
 	if err != nil {
 		return Asset{}, fmt.Errorf("readAsset: %s", err)
@@ -177,15 +291,72 @@ func readAsset(root, filename string) (asset Asset, err error) {
 		return Asset{}, fmt.Errorf("readAsset: %s", err)
 	}
 
-	contentType := http.DetectContentType(buffer)
-	if strings.Contains(contentType, "text") {
-		asset.Value = string(buffer)
-		asset.Checksum = calculateTextChecksum(asset.Value, filepath.Ext(asset.Key) == ".json")
-	} else {
-		asset.Attachment = base64.StdEncoding.EncodeToString(buffer)
-		asset.Checksum = calculateByteArrayChecksum(buffer)
+	return NewAsset(filepath.ToSlash(key), buffer), nil
+}
+
+// scanAsset will calculate the checksum of a file the same way as NewAsset, only
+// reading as much of the file at once as is needed to detect its content type.
+func scanAsset(root string, cache ChecksumCache, filename string) (LocalAsset, error) {
+	path := filepath.Join(root, filename)
+
+	key, err := filepath.Rel(root, path)
+	if err != nil {
+		return LocalAsset{}, err
+	}
+	key = filepath.ToSlash(key)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return LocalAsset{}, fmt.Errorf("scanAsset: %s", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return LocalAsset{}, fmt.Errorf("scanAsset: %s", err)
+	} else if info.IsDir() {
+		return LocalAsset{}, ErrAssetIsDir
+	}
+
+	if cache != nil {
+		if checksum, ok := cache.LocalChecksum(key, info.Size(), info.ModTime()); ok {
+			return LocalAsset{Key: key, Checksum: checksum}, nil
+		}
+	}
+
+	checksum, err := readChecksum(file, key)
+	if err != nil {
+		return LocalAsset{}, fmt.Errorf("scanAsset: %s", err)
+	}
+	if cache != nil {
+		cache.SetLocalChecksum(key, info.Size(), info.ModTime(), checksum)
+	}
+	return LocalAsset{Key: key, Checksum: checksum}, nil
+}
+
+func readChecksum(file io.Reader, key string) (string, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	isText := strings.Contains(http.DetectContentType(head), "text")
+	if isText && filepath.Ext(key) == ".json" {
+		rest, err := ioutil.ReadAll(file)
+		if err != nil {
+			return "", err
+		}
+		return calculateTextChecksum(string(append(head, rest...)), true), nil
+	}
+
+	hash := md5.New()
+	hash.Write(head)
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
 	}
-	return asset, nil
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
 func calculateTextChecksum(value string, isJSON bool) (checksum string) {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -40,6 +41,105 @@ func TestFindAssets(t *testing.T) {
 	}
 }
 
+func TestScanAssets(t *testing.T) {
+	goodEnv := &env.Env{Directory: filepath.Join("_testdata", "project")}
+	badEnv := &env.Env{Directory: "nope"}
+
+	testcases := []struct {
+		e      *env.Env
+		inputs []string
+		err    string
+		count  int
+	}{
+		{e: goodEnv, inputs: []string{filepath.Join("assets", "application.js")}, count: 1},
+		{e: goodEnv, count: 10},
+		{e: goodEnv, inputs: []string{"assets"}, count: 5},
+		{e: badEnv, err: " "},
+		{e: goodEnv, inputs: []string{"snippets/nope.txt"}, err: "scanAsset: "},
+	}
+
+	for _, testcase := range testcases {
+		assets, err := ScanAssets(testcase.e, nil, testcase.inputs...)
+		if testcase.err == "" {
+			assert.Nil(t, err)
+			assert.Equal(t, testcase.count, len(assets))
+		} else if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), testcase.err)
+		}
+	}
+}
+
+func TestAssetKeys(t *testing.T) {
+	keys, err := AssetKeys(&env.Env{Directory: filepath.Join("_testdata", "project")})
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(keys))
+	assert.Contains(t, keys, "assets/application.js")
+
+	_, err = AssetKeys(&env.Env{Directory: "nope"})
+	assert.NotNil(t, err)
+}
+
+func TestScanAssets_Checksums(t *testing.T) {
+	e := &env.Env{Directory: filepath.Join("_testdata", "project")}
+	found, err := FindAssets(e)
+	assert.Nil(t, err)
+	scanned, err := ScanAssets(e, nil)
+	assert.Nil(t, err)
+
+	expected := map[string]string{}
+	for _, asset := range found {
+		expected[asset.Key] = asset.Checksum
+	}
+	for _, asset := range scanned {
+		assert.Equal(t, expected[asset.Key], asset.Checksum, asset.Key)
+		read, err := asset.Read(e)
+		assert.Nil(t, err)
+		assert.Equal(t, asset.Checksum, read.Checksum)
+	}
+	assert.Equal(t, len(found), len(scanned))
+}
+
+type testChecksumCache map[string]string
+
+func (cache testChecksumCache) LocalChecksum(key string, size int64, modTime time.Time) (string, bool) {
+	checksum, ok := cache[key]
+	return checksum, ok
+}
+
+func (cache testChecksumCache) SetLocalChecksum(key string, size int64, modTime time.Time, checksum string) {
+	cache[key] = checksum
+}
+
+func TestScanAssets_Cache(t *testing.T) {
+	e := &env.Env{Directory: filepath.Join("_testdata", "project")}
+	cache := testChecksumCache{"assets/application.js": "cached"}
+
+	assets, err := ScanAssets(e, cache, "assets")
+	assert.Nil(t, err)
+	for _, asset := range assets {
+		if asset.Key == "assets/application.js" {
+			assert.Equal(t, "cached", asset.Checksum)
+		} else {
+			assert.Equal(t, cache[asset.Key], asset.Checksum)
+		}
+	}
+	assert.Equal(t, len(assets), len(cache))
+}
+
+func TestReadChecksum(t *testing.T) {
+	e := &env.Env{Directory: filepath.Join("_testdata", "project")}
+
+	checksum, err := ReadChecksum(e, nil, filepath.Join("assets", "application.js"))
+	assert.Nil(t, err)
+	assert.Equal(t, "f980fcdcfeb5bcf24c0de5c199c3a94b", checksum)
+
+	_, err = ReadChecksum(e, nil, "assets")
+	assert.Equal(t, ErrAssetIsDir, err)
+
+	_, err = ReadChecksum(e, nil, "nope.txt")
+	assert.NotNil(t, err)
+}
+
 func TestAsset_Write(t *testing.T) {
 	testDir := filepath.Join("_testdata", "writeto")
 	os.Mkdir(testDir, 0755)
@@ -79,7 +179,7 @@ func TestAsset_Contents(t *testing.T) {
 	}
 
 	for _, testcase := range testcases {
-		data, err := testcase.asset.contents()
+		data, err := testcase.asset.Contents()
 		if testcase.err == "" {
 			assert.Nil(t, err)
 			assert.Equal(t, testcase.length, len(data))
@@ -116,6 +216,17 @@ func TestLoadAssetsFromDirectory(t *testing.T) {
 	}
 }
 
+func TestNewAsset(t *testing.T) {
+	asset := NewAsset("config/settings_data.json", []byte("{\n  \"testing\" : \"data\"\n}"))
+	assert.Equal(t, "config/settings_data.json", asset.Key)
+	assert.Equal(t, "31409bedd9f5852166c0a4a9b874f1a7", asset.Checksum)
+	assert.Equal(t, "", asset.Attachment)
+
+	asset = NewAsset("assets/blob.bin", []byte{0, 1, 2})
+	assert.Equal(t, "", asset.Value)
+	assert.Equal(t, "AAEC", asset.Attachment)
+}
+
 func TestReadAsset(t *testing.T) {
 	e := &env.Env{Directory: filepath.Join("_testdata", "project")}
 
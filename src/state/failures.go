@@ -0,0 +1,59 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Failure is a file operation that failed, and why
+type Failure struct {
+	Path  string `json:"path"`
+	Cause string `json:"cause"`
+}
+
+// SaveFailures will record the file operations that failed the last time the
+// command ran, replacing the failures recorded before. If nothing failed then the
+// recorded failures are removed. Nothing is recorded for a Sync that is only kept
+// in memory.
+func (s *Sync) SaveFailures(command string, failures []Failure) error {
+	if !s.onDisk() {
+		return nil
+	}
+	path := s.failuresPath(command)
+	if len(failures) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(failures, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Failures will return the file operations that failed the last time the command
+// ran. If nothing was recorded then no failures are returned.
+func (s *Sync) Failures(command string) ([]Failure, error) {
+	failures := []Failure{}
+	if s == nil || s.path == "" {
+		return failures, nil
+	}
+	data, err := ioutil.ReadFile(s.failuresPath(command))
+	if os.IsNotExist(err) {
+		return failures, nil
+	} else if err != nil {
+		return failures, err
+	}
+	return failures, json.Unmarshal(data, &failures)
+}
+
+func (s *Sync) failuresPath(command string) string {
+	return filepath.Join(filepath.Dir(s.path), "failures-"+command+".json")
+}
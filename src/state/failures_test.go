@@ -0,0 +1,47 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/env"
+)
+
+func TestFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-failures")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := Load(&env.Env{Name: "development", Directory: dir, ThemeID: "123"})
+	assert.Nil(t, err)
+
+	failures, err := s.Failures("deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, []Failure{}, failures)
+
+	saved := []Failure{{Path: "assets/app.js", Cause: "server error"}}
+	assert.Nil(t, s.SaveFailures("deploy", saved))
+	failures, err = s.Failures("deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, saved, failures)
+
+	failures, err = s.Failures("download")
+	assert.Nil(t, err)
+	assert.Equal(t, []Failure{}, failures)
+
+	path := filepath.Join(Dir(&env.Env{Name: "development", Directory: dir}), "failures-deploy.json")
+	assert.True(t, fileExists(path))
+	assert.Nil(t, s.SaveFailures("deploy", []Failure{}))
+	assert.False(t, fileExists(path))
+	assert.Nil(t, s.SaveFailures("deploy", nil))
+
+	var memory *Sync
+	assert.Nil(t, memory.SaveFailures("deploy", saved))
+	failures, err = memory.Failures("deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, []Failure{}, failures)
+}
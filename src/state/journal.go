@@ -0,0 +1,166 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Shopify/themekit/src/file"
+)
+
+// ErrNoJournal is returned when resuming a command that has no unfinished journal
+var ErrNoJournal = errors.New("no unfinished journal")
+
+// JournalEntry is a single planned file operation
+type JournalEntry struct {
+	Path string  `json:"path"`
+	Op   file.Op `json:"op"`
+}
+
+// Journal records the file operations planned by a command, in the steps they are
+// run in, and which of them have completed so that the command can be resumed if
+// it does not finish. The plan is written as the first line of the journal file
+// and every completed operation is appended as a line after it. All methods are
+// safe to call on a nil Journal, in which case nothing is recorded.
+type Journal struct {
+	Steps [][]JournalEntry `json:"steps"`
+
+	path string
+	mu   sync.Mutex
+	file *os.File
+	done map[string]bool
+}
+
+type journalDone struct {
+	Done string `json:"done"`
+}
+
+// StartJournal will start a new journal for the command, replacing any unfinished
+// journal of the same command. Nothing is recorded for a Sync that is only kept in
+// memory.
+func (s *Sync) StartJournal(command string, steps [][]JournalEntry) (*Journal, error) {
+	if !s.onDisk() {
+		return nil, nil
+	}
+	j := &Journal{Steps: steps, path: s.journalPath(command), done: map[string]bool{}}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+	if j.file, err = os.Create(j.path); err != nil {
+		return nil, err
+	}
+	if _, err := j.file.Write(append(header, '\n')); err != nil {
+		j.file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// ResumeJournal will load the unfinished journal of the command so that more
+// completed operations can be recorded in it. ErrNoJournal is returned if there is
+// no unfinished journal.
+func (s *Sync) ResumeJournal(command string) (*Journal, error) {
+	if s == nil || s.path == "" {
+		return nil, ErrNoJournal
+	}
+	j := &Journal{path: s.journalPath(command), done: map[string]bool{}}
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoJournal
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	if !scanner.Scan() {
+		return nil, ErrNoJournal
+	} else if err := json.Unmarshal(scanner.Bytes(), j); err != nil {
+		return nil, err
+	}
+	for scanner.Scan() {
+		var line journalDone
+		// the last line may have been cut off if the command was killed
+		if json.Unmarshal(scanner.Bytes(), &line) == nil && line.Done != "" {
+			j.done[line.Done] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if j.file, err = os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Remaining will return the planned operations that have not completed, in the
+// steps they were planned in. Steps that have completed are left out.
+func (j *Journal) Remaining() [][]JournalEntry {
+	remaining := [][]JournalEntry{}
+	if j == nil {
+		return remaining
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, step := range j.Steps {
+		left := []JournalEntry{}
+		for _, entry := range step {
+			if !j.done[entry.Path] {
+				left = append(left, entry)
+			}
+		}
+		if len(left) > 0 {
+			remaining = append(remaining, left)
+		}
+	}
+	return remaining
+}
+
+// Done will record that the operation on the path has completed
+func (j *Journal) Done(path string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	line, err := json.Marshal(journalDone{Done: path})
+	if err != nil {
+		return err
+	}
+	j.done[path] = true
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close will stop recording to the journal, leaving it to be resumed
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// Finish will remove the journal because all of its operations have completed
+func (j *Journal) Finish() error {
+	if j == nil {
+		return nil
+	}
+	j.Close()
+	return os.Remove(j.path)
+}
+
+func (s *Sync) journalPath(command string) string {
+	return filepath.Join(filepath.Dir(s.path), "journal-"+command+".jsonl")
+}
@@ -0,0 +1,77 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/file"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-journal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := Load(&env.Env{Name: "development", Directory: dir, ThemeID: "123"})
+	assert.Nil(t, err)
+
+	_, err = s.ResumeJournal("deploy")
+	assert.Equal(t, ErrNoJournal, err)
+
+	steps := [][]JournalEntry{
+		{{Path: "assets/app.js", Op: file.Update}, {Path: "snippets/a.liquid", Op: file.Update}},
+		{{Path: "layout/theme.liquid", Op: file.Update}},
+		{{Path: "assets/old.js", Op: file.Remove}},
+	}
+	j, err := s.StartJournal("deploy", steps)
+	assert.Nil(t, err)
+	assert.Nil(t, j.Done("assets/app.js"))
+	assert.Nil(t, j.Done("snippets/a.liquid"))
+	assert.Nil(t, j.Close())
+
+	// a line that was cut off when the command was killed is ignored
+	path := filepath.Join(Dir(&env.Env{Name: "development", Directory: dir}), "journal-deploy.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	f.WriteString(`{"done": "layout/the`)
+	f.Close()
+
+	j, err = s.ResumeJournal("deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]JournalEntry{
+		{{Path: "layout/theme.liquid", Op: file.Update}},
+		{{Path: "assets/old.js", Op: file.Remove}},
+	}, j.Remaining())
+	assert.Nil(t, j.Finish())
+	assert.False(t, fileExists(path))
+
+	_, err = s.ResumeJournal("download")
+	assert.Equal(t, ErrNoJournal, err)
+}
+
+func TestNilJournal(t *testing.T) {
+	var s *Sync
+	j, err := s.StartJournal("deploy", [][]JournalEntry{})
+	assert.Nil(t, err)
+	assert.Nil(t, j)
+	assert.Nil(t, j.Done("assets/app.js"))
+	assert.Equal(t, [][]JournalEntry{}, j.Remaining())
+	assert.Nil(t, j.Close())
+	assert.Nil(t, j.Finish())
+
+	j, err = (&Sync{}).StartJournal("deploy", [][]JournalEntry{})
+	assert.Nil(t, err)
+	assert.Nil(t, j)
+	_, err = (&Sync{}).ResumeJournal("deploy")
+	assert.Equal(t, ErrNoJournal, err)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
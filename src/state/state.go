@@ -0,0 +1,289 @@
+// Package state keeps track of what themekit knows about a theme between runs so
+// that changes made on shopify since the last sync can be detected.
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+const (
+	// DirName is the name of the directory, inside of the project directory, where
+	// themekit keeps its state
+	DirName   = ".themekit"
+	stateFile = "state.json"
+	baseDir   = "base"
+	// a file modified this recently may still be modified again within the same
+	// modification time, so its checksum is not cached
+	racyWindow = 2 * time.Second
+)
+
+// Entry is what is known about a single asset since it was last synced
+type Entry struct {
+	// Checksum is the checksum the asset had on shopify after the last successful sync
+	Checksum string `json:"checksum"`
+	// Size, ModTime and LocalChecksum describe the local file when its checksum was
+	// last calculated, so that it does not need to be read again while unchanged
+	Size          int64  `json:"size,omitempty"`
+	ModTime       int64  `json:"mtime,omitempty"`
+	LocalChecksum string `json:"local_checksum,omitempty"`
+}
+
+// Sync records the state of every asset at the last successful sync with shopify
+// for a single environment. All methods are safe to call on a nil Sync, in which
+// case nothing is recorded. A Sync that was not loaded from disk is only kept in
+// memory.
+type Sync struct {
+	ThemeID string           `json:"theme_id"`
+	Assets  map[string]Entry `json:"assets"`
+	// ListedAt is the last time that all of the assets on shopify were listed
+	ListedAt time.Time `json:"listed_at"`
+
+	path      string
+	mu        sync.RWMutex
+	dirty     bool
+	firstSync bool
+	inMemory  bool
+}
+
+// Dir will return the directory that state is kept in for an environment
+func Dir(e *env.Env) string {
+	return filepath.Join(e.Directory, DirName, e.Name)
+}
+
+// Load will read the sync state for an environment. If no state has been
+// recorded yet, or if the state was recorded for a different theme, then an empty
+// state is returned.
+func Load(e *env.Env) (*Sync, error) {
+	s := &Sync{
+		ThemeID:   e.ThemeID,
+		Assets:    map[string]Entry{},
+		path:      filepath.Join(Dir(e), stateFile),
+		firstSync: true,
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+
+	var saved Sync
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return s, err
+	}
+	if saved.ThemeID == e.ThemeID && saved.Assets != nil {
+		s.Assets = saved.Assets
+		s.ListedAt = saved.ListedAt
+	}
+	for _, entry := range s.Assets {
+		if entry.Checksum != "" {
+			s.firstSync = false
+			break
+		}
+	}
+	return s, nil
+}
+
+// FirstSync will return true if no asset had been synced when the state was
+// loaded, like in a project that was set up before themekit kept state. There is
+// then nothing to tell local changes apart from changes made on shopify.
+func (s *Sync) FirstSync() bool {
+	return s == nil || s.firstSync
+}
+
+// KeepInMemory will stop the state from writing anything to disk from now on. It
+// can still be read from disk and changed in memory, which is what a dry run needs.
+func (s *Sync) KeepInMemory() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inMemory = true
+}
+
+// Checksum will return the checksum the asset had at the last successful sync
+func (s *Sync) Checksum(key string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.Assets[key]
+	return entry.Checksum, ok && entry.Checksum != ""
+}
+
+// Synced will record that the asset has been synced and has the checksum provided
+func (s *Sync) Synced(key, checksum string) {
+	if s == nil || checksum == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry := s.Assets[key]; entry.Checksum != checksum {
+		entry.Checksum = checksum
+		s.Assets[key] = entry
+		s.dirty = true
+	}
+}
+
+// Checksums will return the checksum every synced asset had at its last sync
+func (s *Sync) Checksums() map[string]string {
+	checksums := map[string]string{}
+	if s == nil {
+		return checksums
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key, entry := range s.Assets {
+		if entry.Checksum != "" {
+			checksums[key] = entry.Checksum
+		}
+	}
+	return checksums
+}
+
+// Listed will record that all of the assets on shopify have just been listed
+func (s *Sync) Listed() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ListedAt = time.Now()
+	s.dirty = true
+}
+
+// Fresh will return true if the assets on shopify were listed within maxAge, in
+// which case the recorded checksums can be used instead of listing them again.
+func (s *Sync) Fresh(maxAge time.Duration) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.ListedAt.IsZero() && time.Since(s.ListedAt) < maxAge
+}
+
+// LocalChecksum will return the checksum that was calculated for the local file if
+// it has the same size and modification time as when it was calculated
+func (s *Sync) LocalChecksum(key string, size int64, modTime time.Time) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.Assets[key]
+	if !ok || entry.LocalChecksum == "" || entry.Size != size || entry.ModTime != modTime.UnixNano() {
+		return "", false
+	}
+	return entry.LocalChecksum, true
+}
+
+// SetLocalChecksum will remember the checksum calculated for the local file
+func (s *Sync) SetLocalChecksum(key string, size int64, modTime time.Time, checksum string) {
+	if s == nil || checksum == "" || time.Since(modTime) < racyWindow {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.Assets[key]
+	if entry.Size == size && entry.ModTime == modTime.UnixNano() && entry.LocalChecksum == checksum {
+		return
+	}
+	entry.Size, entry.ModTime, entry.LocalChecksum = size, modTime.UnixNano(), checksum
+	s.Assets[key] = entry
+	s.dirty = true
+}
+
+// Forget will remove any record of the asset
+func (s *Sync) Forget(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Assets[key]; ok {
+		delete(s.Assets, key)
+		s.dirty = true
+	}
+	if s.path != "" && !s.inMemory {
+		os.Remove(s.basePath(key))
+	}
+}
+
+// Base will return the contents the asset had at the last successful sync. Contents
+// are only kept for assets that were recorded with SetBase and are only returned
+// if they still match the checksum of the last sync.
+func (s *Sync) Base(key string) ([]byte, bool) {
+	checksum, ok := s.Checksum(key)
+	if !ok || s.path == "" {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(s.basePath(key))
+	if err != nil || shopify.NewAsset(key, data).Checksum != checksum {
+		return nil, false
+	}
+	return data, true
+}
+
+// SetBase will keep the contents of the asset as they were synced so that they
+// can be used to merge changes made after this sync.
+func (s *Sync) SetBase(key string, contents []byte) error {
+	if !s.onDisk() {
+		return nil
+	}
+	path := s.basePath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, contents, 0644)
+}
+
+// onDisk will return true if the state is written to disk
+func (s *Sync) onDisk() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.path != "" && !s.inMemory
+}
+
+func (s *Sync) basePath(key string) string {
+	return filepath.Join(filepath.Dir(s.path), baseDir, filepath.FromSlash(key))
+}
+
+// Save will write the state to disk if anything has changed since it was loaded
+func (s *Sync) Save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty || s.path == "" || s.inMemory {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.path, data, 0644); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,193 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/env"
+	"github.com/Shopify/themekit/src/shopify"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-state")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	e := &env.Env{Name: "development", Directory: dir, ThemeID: "123"}
+	s, err := Load(e)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]Entry{}, s.Assets)
+	assert.True(t, s.FirstSync())
+
+	s.Synced("assets/app.js", "abc")
+	assert.Nil(t, s.Save())
+	assert.FileExists(t, filepath.Join(dir, DirName, "development", "state.json"))
+
+	s, err = Load(e)
+	assert.Nil(t, err)
+	checksum, ok := s.Checksum("assets/app.js")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", checksum)
+	assert.False(t, s.FirstSync())
+
+	s, err = Load(&env.Env{Name: "development", Directory: dir, ThemeID: "456"})
+	assert.Nil(t, err)
+	_, ok = s.Checksum("assets/app.js")
+	assert.False(t, ok)
+	assert.True(t, s.FirstSync())
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, DirName, "development", "state.json"), []byte("nope"), 0644))
+	s, err = Load(e)
+	assert.NotNil(t, err)
+	assert.NotNil(t, s)
+}
+
+func TestSyncedAndForget(t *testing.T) {
+	s := &Sync{Assets: map[string]Entry{}}
+
+	s.Synced("assets/app.js", "")
+	assert.False(t, s.dirty)
+	_, ok := s.Checksum("assets/app.js")
+	assert.False(t, ok)
+
+	s.Synced("assets/app.js", "abc")
+	assert.True(t, s.dirty)
+	checksum, ok := s.Checksum("assets/app.js")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", checksum)
+
+	s.Forget("assets/app.js")
+	_, ok = s.Checksum("assets/app.js")
+	assert.False(t, ok)
+}
+
+func TestNilSync(t *testing.T) {
+	var s *Sync
+	s.Synced("assets/app.js", "abc")
+	s.Forget("assets/app.js")
+	_, ok := s.Checksum("assets/app.js")
+	assert.False(t, ok)
+	assert.True(t, s.FirstSync())
+	assert.Nil(t, s.Save())
+}
+
+func TestSaveOnlyWhenChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-state")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := Load(&env.Env{Name: "development", Directory: dir})
+	assert.Nil(t, s.Save())
+	_, err = os.Stat(filepath.Join(dir, DirName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestKeepInMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-state")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := Load(&env.Env{Name: "development", Directory: dir})
+	s.KeepInMemory()
+	s.Synced("templates/index.json", "abc")
+	s.Listed()
+	assert.Nil(t, s.SetBase("templates/index.json", []byte("{}")))
+	assert.Nil(t, s.SaveFailures("download", []Failure{{Path: "assets/app.js", Cause: "server error"}}))
+	journal, err := s.StartJournal("download", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, journal)
+	assert.Nil(t, s.Save())
+	_, err = os.Stat(filepath.Join(dir, DirName))
+	assert.True(t, os.IsNotExist(err))
+
+	checksum, ok := s.Checksum("templates/index.json")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", checksum)
+
+	var nilSync *Sync
+	nilSync.KeepInMemory()
+}
+
+func TestBase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "themekit-state")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := Load(&env.Env{Name: "development", Directory: dir})
+	_, ok := s.Base("templates/index.json")
+	assert.False(t, ok)
+
+	contents := []byte(`{"sections": {}}`)
+	s.Synced("templates/index.json", "d2e6b8c1ae10d2cb0ca1c0b7c6fcaa2e")
+	assert.Nil(t, s.SetBase("templates/index.json", contents))
+	assert.FileExists(t, filepath.Join(dir, DirName, "development", "base", "templates", "index.json"))
+	_, ok = s.Base("templates/index.json")
+	assert.False(t, ok, "base does not match the synced checksum")
+
+	s.Synced("templates/index.json", checksumOf(contents))
+	base, ok := s.Base("templates/index.json")
+	assert.True(t, ok)
+	assert.Equal(t, contents, base)
+
+	s.Forget("templates/index.json")
+	_, err = os.Stat(filepath.Join(dir, DirName, "development", "base", "templates", "index.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	var nilSync *Sync
+	assert.Nil(t, nilSync.SetBase("templates/index.json", contents))
+	_, ok = nilSync.Base("templates/index.json")
+	assert.False(t, ok)
+}
+
+func TestLocalChecksum(t *testing.T) {
+	s := &Sync{Assets: map[string]Entry{}}
+	modTime := time.Now().Add(-time.Hour)
+
+	_, ok := s.LocalChecksum("assets/app.js", 10, modTime)
+	assert.False(t, ok)
+
+	s.SetLocalChecksum("assets/app.js", 10, modTime, "local")
+	checksum, ok := s.LocalChecksum("assets/app.js", 10, modTime)
+	assert.True(t, ok)
+	assert.Equal(t, "local", checksum)
+
+	_, ok = s.LocalChecksum("assets/app.js", 11, modTime)
+	assert.False(t, ok)
+	_, ok = s.LocalChecksum("assets/app.js", 10, modTime.Add(time.Second))
+	assert.False(t, ok)
+
+	s.Synced("assets/app.js", "remote")
+	checksum, ok = s.LocalChecksum("assets/app.js", 10, modTime)
+	assert.True(t, ok)
+	assert.Equal(t, "local", checksum)
+	_, synced := s.Checksum("assets/app.js")
+	assert.True(t, synced)
+
+	s.SetLocalChecksum("assets/new.js", 10, time.Now(), "local")
+	_, ok = s.LocalChecksum("assets/new.js", 10, time.Now())
+	assert.False(t, ok)
+	_, synced = s.Checksum("assets/new.js")
+	assert.False(t, synced)
+	assert.Equal(t, map[string]string{"assets/app.js": "remote"}, s.Checksums())
+}
+
+func TestFresh(t *testing.T) {
+	var nilSync *Sync
+	assert.False(t, nilSync.Fresh(time.Hour))
+
+	s := &Sync{Assets: map[string]Entry{}}
+	assert.False(t, s.Fresh(time.Hour))
+	s.Listed()
+	assert.True(t, s.Fresh(time.Hour))
+	s.ListedAt = time.Now().Add(-2 * time.Hour)
+	assert.False(t, s.Fresh(time.Hour))
+}
+
+func checksumOf(contents []byte) string {
+	return shopify.NewAsset("templates/index.json", contents).Checksum
+}
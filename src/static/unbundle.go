@@ -2,13 +2,11 @@ package static
 
 import (
 	"archive/zip"
-	"io"
-	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/Shopify/themekit/src/cmdutil"
-	"github.com/Shopify/themekit/src/colors"
 )
 
 var zipData string
@@ -18,14 +16,20 @@ func Register(data string) {
 	zipData = data
 }
 
-// Unbundle will saftely put all files in place without overwriting files that
-// already exist
-func Unbundle(ctx *cmdutil.Ctx) error {
+// Unbundle will put all files in place, treating files that already exist the way
+// the on-exists flag says, which by default leaves them as they are. The names of
+// the files that were written are returned, files that were kept are left out.
+func Unbundle(ctx *cmdutil.Ctx) ([]string, error) {
 	files, err := getZipContents(zipData)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return createDirs(ctx, files)
+	w := NewWriter(ctx, ctx.Flags.Directory)
+	if err := writeFiles(w, files); err != nil {
+		return nil, err
+	}
+	w.Report()
+	return w.Keys(), nil
 }
 
 func getZipContents(data string) (map[string]map[string]*zip.File, error) {
@@ -44,49 +48,26 @@ func getZipContents(data string) (map[string]map[string]*zip.File, error) {
 	return files, nil
 }
 
-func createDirs(ctx *cmdutil.Ctx, dirFiles map[string]map[string]*zip.File) error {
-	for dir, files := range dirFiles {
-		dir = filepath.Join(ctx.Flags.Directory, dir)
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return err
-			}
-			ctx.Log.Printf("%s %s.\n", colors.Green("Created"), dir)
-		} else {
-			ctx.Log.Printf("%s %s.\n", colors.Blue("Exists"), dir)
-		}
-		for path, file := range files {
-			path = filepath.Join(ctx.Flags.Directory, path)
-			if err := writeFile(ctx, path, file); err != nil {
-				return err
-			}
+func writeFiles(w *Writer, dirFiles map[string]map[string]*zip.File) error {
+	names := []string{}
+	all := map[string]*zip.File{}
+	for _, files := range dirFiles {
+		for name, file := range files {
+			names = append(names, name)
+			all[name] = file
 		}
 	}
-	return nil
-}
-
-func writeFile(ctx *cmdutil.Ctx, path string, zfile *zip.File) error {
-	if _, err := os.Stat(path); err == nil {
-		ctx.Log.Printf("\t%s %s.\n", colors.Blue("Exists"), path)
-		return nil
-	}
-
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	defer file.Sync()
-
-	contents, err := zfile.Open()
-	if err != nil {
-		return err
-	}
-	defer contents.Close()
-
-	_, err = io.Copy(file, contents)
-	if err == nil {
-		ctx.Log.Printf("\t%s %s.\n", colors.Green("Created"), file.Name())
+	sort.Strings(names)
+	for _, name := range names {
+		contents, err := all[name].Open()
+		if err != nil {
+			return err
+		}
+		err = w.Write(name, contents)
+		contents.Close()
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
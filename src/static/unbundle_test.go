@@ -38,7 +38,9 @@ func TestUnbundle(t *testing.T) {
 	}
 
 	Register(testData)
-	assert.Nil(t, Unbundle(ctx))
+	keys, err := Unbundle(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"assets/application.js"}, keys)
 
 	files := map[string][]string{}
 	filepath.Walk(testdirpath, func(path string, info os.FileInfo, err error) error {
@@ -0,0 +1,135 @@
+package static
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+	"github.com/Shopify/themekit/src/colors"
+	"github.com/Shopify/themekit/src/state"
+)
+
+// These are the ways that a file that already exists in the project directory can
+// be treated when files are put in place.
+const (
+	ExistsSkip      = "skip"
+	ExistsOverwrite = "overwrite"
+	ExistsBackup    = "backup"
+	ExistsPrompt    = "prompt"
+)
+
+// backupDir is where existing files are moved to, inside of the project directory,
+// when they are backed up. It is never deployed.
+var backupDir = filepath.Join(state.DirName, "backup")
+
+// Writer puts files in a project directory, treating files that already exist the
+// way the on-exists flag says, and keeps track of what it has done.
+type Writer struct {
+	ctx                                     *cmdutil.Ctx
+	root                                    string
+	keys                                    []string
+	created, overwritten, backedUp, skipped int
+}
+
+// NewWriter will create a writer that puts files in the root directory
+func NewWriter(ctx *cmdutil.Ctx, root string) *Writer {
+	return &Writer{ctx: ctx, root: root}
+}
+
+// Write will put a file in place, name is the slash separated path of the file in
+// the root directory
+func (w *Writer) Write(name string, contents io.Reader) error {
+	path := filepath.Join(w.root, filepath.FromSlash(name))
+
+	if _, err := os.Stat(path); err != nil {
+		if err := writeFile(path, contents); err != nil {
+			return err
+		}
+		w.keys = append(w.keys, name)
+		w.created++
+		w.ctx.Log.Printf("\t%s %s.\n", colors.Green("Created"), path)
+		return nil
+	}
+
+	switch w.ctx.Flags.OnExists {
+	case ExistsOverwrite:
+	case ExistsBackup:
+		backup := filepath.Join(w.root, backupDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(backup), 0755); err != nil {
+			return err
+		} else if err := os.Rename(path, backup); err != nil {
+			return err
+		}
+		w.backedUp++
+		w.ctx.Log.Printf("\t%s %s to %s.\n", colors.Yellow("Backed up"), path, backup)
+	case ExistsPrompt:
+		if !w.ctx.Confirm(fmt.Sprintf("%s exists, overwrite it?", path)) {
+			return w.skip(path)
+		}
+	default:
+		return w.skip(path)
+	}
+
+	if err := writeFile(path, contents); err != nil {
+		return err
+	}
+	w.keys = append(w.keys, name)
+	if w.ctx.Flags.OnExists != ExistsBackup {
+		w.overwritten++
+		w.ctx.Log.Printf("\t%s %s.\n", colors.Yellow("Overwrote"), path)
+	}
+	return nil
+}
+
+// Keys will return the sorted names of the files that were written. Files that
+// already existed and were kept are left out.
+func (w *Writer) Keys() []string {
+	keys := append([]string{}, w.keys...)
+	sort.Strings(keys)
+	return keys
+}
+
+// Report will print how many files were created, overwritten, backed up and skipped
+func (w *Writer) Report() {
+	results := []string{}
+	for _, result := range []struct {
+		label string
+		count int
+	}{
+		{colors.Green("Created"), w.created},
+		{colors.Yellow("Overwritten"), w.overwritten},
+		{colors.Yellow("Backed up"), w.backedUp},
+		{colors.Blue("Skipped"), w.skipped},
+	} {
+		if result.count > 0 {
+			results = append(results, fmt.Sprintf("%v: %v", result.label, result.count))
+		}
+	}
+	if len(results) > 0 {
+		w.ctx.Log.Printf("%d files, %s", w.created+w.overwritten+w.backedUp+w.skipped, strings.Join(results, ", "))
+	}
+}
+
+func (w *Writer) skip(path string) error {
+	w.skipped++
+	w.ctx.Log.Printf("\t%s %s.\n", colors.Blue("Exists"), path)
+	return nil
+}
+
+func writeFile(path string, contents io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	defer file.Sync()
+	_, err = io.Copy(file, contents)
+	return err
+}
@@ -0,0 +1,65 @@
+package static
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Shopify/themekit/src/cmdutil"
+)
+
+func TestWriter(t *testing.T) {
+	written := []string{"assets/app.js", "layout/theme.liquid"}
+	testcases := []struct {
+		onExists, answer, expected, report string
+		backup                             bool
+		keys                               []string
+	}{
+		{onExists: "", expected: "mine", report: "2 files, Created: 1, Skipped: 1", keys: []string{"assets/app.js"}},
+		{onExists: ExistsSkip, expected: "mine", report: "2 files, Created: 1, Skipped: 1", keys: []string{"assets/app.js"}},
+		{onExists: ExistsOverwrite, expected: "new", report: "2 files, Created: 1, Overwritten: 1", keys: written},
+		{onExists: ExistsBackup, expected: "new", report: "2 files, Created: 1, Backed up: 1", backup: true, keys: written},
+		{onExists: ExistsPrompt, answer: "y\n", expected: "new", report: "2 files, Created: 1, Overwritten: 1", keys: written},
+		{onExists: ExistsPrompt, answer: "n\n", expected: "mine", report: "2 files, Created: 1, Skipped: 1", keys: []string{"assets/app.js"}},
+	}
+
+	for _, testcase := range testcases {
+		dir, err := ioutil.TempDir("", "themekit-writer")
+		assert.Nil(t, err)
+		existing := filepath.Join(dir, "layout", "theme.liquid")
+		assert.Nil(t, os.MkdirAll(filepath.Dir(existing), 0755))
+		assert.Nil(t, ioutil.WriteFile(existing, []byte("mine"), 0644))
+
+		stdOut := bytes.NewBufferString("")
+		ctx := &cmdutil.Ctx{Flags: cmdutil.Flags{OnExists: testcase.onExists}, Log: log.New(stdOut, "", 0)}
+		if testcase.answer != "" {
+			ctx.In = strings.NewReader(testcase.answer)
+		}
+		w := NewWriter(ctx, dir)
+		assert.Nil(t, w.Write("layout/theme.liquid", strings.NewReader("new")))
+		assert.Nil(t, w.Write("assets/app.js", strings.NewReader("app")))
+		w.Report()
+
+		contents, _ := ioutil.ReadFile(existing)
+		assert.Equal(t, testcase.expected, string(contents), testcase.onExists)
+		contents, _ = ioutil.ReadFile(filepath.Join(dir, "assets", "app.js"))
+		assert.Equal(t, "app", string(contents), testcase.onExists)
+		contents, err = ioutil.ReadFile(filepath.Join(dir, backupDir, "layout", "theme.liquid"))
+		if testcase.backup {
+			assert.Equal(t, "mine", string(contents))
+			assert.Contains(t, stdOut.String(), "Backed up")
+			assert.NotContains(t, stdOut.String(), "Overwrote")
+		} else {
+			assert.True(t, os.IsNotExist(err), testcase.onExists)
+		}
+		assert.Equal(t, testcase.keys, w.Keys(), testcase.onExists)
+		assert.Contains(t, stdOut.String(), testcase.report, testcase.onExists)
+		os.RemoveAll(dir)
+	}
+}